@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Admonitions ---
+
+// admonitionTypes are the GitHub alert markers, e.g. "> [!NOTE]".
+var admonitionTypes = []string{"note", "tip", "important", "warning", "caution"}
+
+// admonitionExtension turns blockquotes that open with a [!TYPE] line into
+// callouts. Other blockquotes are left alone.
+type admonitionExtension struct{}
+
+func (admonitionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(admonitionTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(admonitionRenderer{}, 100)))
+}
+
+var kindAdmonition = ast.NewNodeKind("Admonition")
+
+type admonition struct {
+	ast.BaseBlock
+	kind string
+}
+
+func (n *admonition) Kind() ast.NodeKind { return kindAdmonition }
+
+func (n *admonition) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Kind": n.kind}, nil)
+}
+
+type admonitionTransformer struct{}
+
+func (admonitionTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var quotes []*ast.Blockquote
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, q := range quotes {
+		para, ok := q.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		marker := para.Lines().At(0)
+		kind := admonitionKind(marker.Value(source))
+		if kind == "" {
+			continue
+		}
+
+		// Drop the inline nodes of the marker line.
+		for c := para.FirstChild(); c != nil; {
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Start >= marker.Stop {
+				break
+			}
+			next := c.NextSibling()
+			para.RemoveChild(para, c)
+			c = next
+		}
+		if para.ChildCount() == 0 {
+			q.RemoveChild(q, para)
+		}
+
+		callout := &admonition{kind: kind}
+		for c := q.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			callout.AppendChild(callout, c)
+			c = next
+		}
+		q.Parent().ReplaceChild(q.Parent(), q, callout)
+	}
+}
+
+// admonitionKind returns the alert type of a "[!TYPE]" line, or "".
+func admonitionKind(line []byte) string {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("[!")) || !bytes.HasSuffix(line, []byte("]")) {
+		return ""
+	}
+	kind := strings.ToLower(string(line[2 : len(line)-1]))
+	if !slices.Contains(admonitionTypes, kind) {
+		return ""
+	}
+	return kind
+}
+
+type admonitionRenderer struct{}
+
+func (r admonitionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindAdmonition, r.render)
+}
+
+func (admonitionRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+	kind := n.(*admonition).kind
+	w.WriteString(`<div class="admonition admonition-` + kind + `">` + "\n")
+	w.WriteString(`<p class="admonition-title">` + strings.ToUpper(kind[:1]) + kind[1:] + "</p>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Heading anchors ---
+
+// anchorExtension appends a "#" permalink to every heading that has an id.
+// The link is hidden until the heading is hovered.
+type anchorExtension struct{}
+
+func (anchorExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(anchorTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(anchorRenderer{}, 100)))
+}
+
+var kindAnchor = ast.NewNodeKind("Anchor")
+
+type anchor struct {
+	ast.BaseInline
+	id []byte
+}
+
+func (n *anchor) Kind() ast.NodeKind { return kindAnchor }
+
+func (n *anchor) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ID": string(n.id)}, nil)
+}
+
+type anchorTransformer struct{}
+
+func (anchorTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if v, ok := h.AttributeString("id"); ok {
+			if id, ok := v.([]byte); ok {
+				h.AppendChild(h, &anchor{id: id})
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+type anchorRenderer struct{}
+
+func (r anchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindAnchor, r.render)
+}
+
+func (anchorRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		id := util.EscapeHTML(n.(*anchor).id)
+		w.WriteString(`<a class="anchor" href="#`)
+		w.Write(id)
+		w.WriteString(`" aria-label="Link to this section">#</a>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
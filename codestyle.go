@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// --- Code styles ---
+
+// lightCodeStyle highlights code in the light theme when --code-style is a
+// dark style, as the default is.
+const lightCodeStyle = "github"
+
+var cssComment = regexp.MustCompile(`/\*.*?\*/\s*`)
+
+// codeStyleSheet returns the chroma classes for code blocks. Unless the
+// theme is fixed, it holds a light and a dark variant that follow the color
+// scheme like the palettes do, see colorScheme.
+func codeStyleSheet(p page, opts options) string {
+	if opts.noHighlight {
+		return ""
+	}
+	dark, light := opts.codeStyle, opts.codeStyle
+	if !isLightStyle(dark) {
+		light = lightCodeStyle
+	}
+
+	switch theme := fixedTheme(p, opts); theme {
+	case "custom":
+		return chromaCSS(dark, "", opts)
+	case "":
+	default:
+		if palettes[theme].dark {
+			return chromaCSS(dark, "", opts)
+		}
+		return chromaCSS(light, "", opts)
+	}
+	if dark == light {
+		return chromaCSS(dark, "", opts)
+	}
+
+	return chromaCSS(light, "", opts) + `
+@media screen and (prefers-color-scheme: dark) {
+` + chromaCSS(dark, `:root:not([data-theme="light"])`, opts) + `
+}
+@media screen {
+` + chromaCSS(dark, `:root[data-theme="dark"]`, opts) + `
+}`
+}
+
+// chromaCSS writes the rules of a chroma style, each selector prefixed
+// with scope if it is set.
+func chromaCSS(name, scope string, opts options) string {
+	var b strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(opts.lineNums))
+	formatter.WriteCSS(&b, styles.Get(name))
+
+	lines := strings.Split(strings.TrimSpace(cssComment.ReplaceAllString(b.String(), "")), "\n")
+	if scope != "" {
+		for i, line := range lines {
+			lines[i] = scope + " " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLightStyle(name string) bool {
+	return styles.Get(name).Get(chroma.Background).Background.Brightness() > 0.5
+}
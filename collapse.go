@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Collapsible code ---
+
+// collapsePreviewLines is how many lines of a collapsed block stay visible.
+// Blocks no longer than that are left open.
+const collapsePreviewLines = 3
+
+// collapseExtension wraps code blocks in <details> with the language, the
+// line count and the first lines as a summary. It runs after the mermaid
+// transformer so diagrams are not collapsed.
+type collapseExtension struct{}
+
+func (collapseExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(collapseTransformer{}, 200)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(collapseRenderer{}, 100)))
+}
+
+var kindCollapsedCode = ast.NewNodeKind("CollapsedCode")
+
+type collapsedCode struct {
+	ast.BaseBlock
+	lang string
+}
+
+func (n *collapsedCode) Kind() ast.NodeKind { return kindCollapsedCode }
+
+func (n *collapsedCode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Lang": n.lang}, nil)
+}
+
+type collapseTransformer struct{}
+
+func (collapseTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var blocks []ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering && n.Lines().Len() > collapsePreviewLines {
+				blocks = append(blocks, n)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		wrapper := &collapsedCode{}
+		if fence, ok := block.(*ast.FencedCodeBlock); ok {
+			wrapper.lang = string(fence.Language(source))
+		}
+		block.Parent().ReplaceChild(block.Parent(), block, wrapper)
+		wrapper.AppendChild(wrapper, block)
+	}
+}
+
+type collapseRenderer struct{}
+
+func (r collapseRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindCollapsedCode, r.render)
+}
+
+func (collapseRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		w.WriteString("</details>\n")
+		return ast.WalkContinue, nil
+	}
+	lines := n.FirstChild().Lines()
+	label := strconv.Itoa(lines.Len()) + " lines"
+	if lang := n.(*collapsedCode).lang; lang != "" {
+		label = lang + " · " + label
+	}
+
+	w.WriteString(`<details class="collapse-code"><summary>`)
+	w.WriteString(`<span class="collapse-label">`)
+	w.Write(util.EscapeHTML([]byte(label)))
+	w.WriteString(`</span><pre class="code-preview"><code>`)
+	for i := 0; i < collapsePreviewLines; i++ {
+		seg := lines.At(i)
+		w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	w.WriteString("</code></pre></summary>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// --- Shell completion ---
+
+var (
+	// longFlag matches every flag mentioned in usage.
+	longFlag = regexp.MustCompile(`--[a-z][\w-]*`)
+	// shortFlag matches a flag listed with both forms, like "-t, --term".
+	shortFlag = regexp.MustCompile(`(?m)^  -(\w), --([\w-]+)`)
+)
+
+// printCompletion prints the completion script for a shell. Flags complete
+// from the ones named in usage, anything else completes as a file name.
+func printCompletion(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: marko completion bash|zsh|fish")
+	}
+
+	var long []string
+	for _, flag := range longFlag.FindAllString(usage, -1) {
+		if !slices.Contains(long, flag) {
+			long = append(long, flag)
+		}
+	}
+	flags := strings.Join(long, " ")
+	shorts := make(map[string]string)
+	for _, m := range shortFlag.FindAllStringSubmatch(usage, -1) {
+		shorts["--"+m[2]] = m[1]
+		flags += " -" + m[1]
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Printf(`_marko() {
+  local cur=${COMP_WORDS[COMP_CWORD]}
+  if [[ $cur == -* ]]; then
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+  fi
+}
+complete -o default -F _marko marko
+`, flags)
+	case "zsh":
+		fmt.Printf(`#compdef marko
+_marko() {
+  if [[ $PREFIX == -* ]]; then
+    compadd -- %s
+  else
+    _files
+  fi
+}
+if [[ $funcstack[1] == _marko ]]; then
+  _marko "$@"
+else
+  compdef _marko marko
+fi
+`, flags)
+	case "fish":
+		fmt.Println("complete -c marko -n __fish_use_subcommand -a completion -d 'Print a shell completion script'")
+		fmt.Println("complete -c marko -n __fish_use_subcommand -a diff -d 'Show the changes between two files'")
+		for _, flag := range long {
+			line := "complete -c marko -l " + strings.TrimPrefix(flag, "--")
+			if s, ok := shorts[flag]; ok {
+				line += " -s " + s
+			}
+			fmt.Println(line)
+		}
+	default:
+		return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Configuration ---
+
+// defaultPager passes colors through (-R), quits when the text fits on one
+// screen (-F) and leaves it on the terminal afterwards (-X).
+const defaultPager = "less -R -F -X"
+
+// loadConfig returns the built-in defaults, overlaid with the config file
+// and then with environment variables. CLI flags are applied on top by
+// parseFlags.
+func loadConfig() (options, error) {
+	opts := options{
+		codeStyle:       defaultCodeStyle,
+		width:           -1,
+		pager:           defaultPager,
+		host:            "127.0.0.1",
+		jobs:            defaultCheckJobs,
+		readerWidth:     720,
+		readerFont:      "sans",
+		reloadTransport: "sse",
+		reloadDebounce:  150 * time.Millisecond,
+		tabSize:         4,
+		tocDepth:        3,
+	}
+
+	if path := configPath(); path != "" {
+		if err := readConfigFile(path, &opts); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return opts, err
+		}
+	}
+
+	if style := os.Getenv("GLAMOUR_STYLE"); style != "" {
+		opts.style = style
+	}
+	if pager := os.Getenv("PAGER"); pager != "" {
+		opts.pager = pager
+	}
+	if style := os.Getenv("MARKO_CODE_STYLE"); style != "" {
+		opts.codeStyle = style
+	}
+	if browser := os.Getenv("MARKO_BROWSER"); browser != "" {
+		opts.openWith = browser
+	}
+
+	return opts, nil
+}
+
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "marko", "config.toml")
+}
+
+// readConfigFile reads flat `key = value` lines, which covers the subset of
+// TOML the config needs. Blank lines, # comments and [section] headers are
+// ignored, and values may be quoted.
+func readConfigFile(path string, opts *options) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+
+		if err := setConfigValue(opts, key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func setConfigValue(opts *options, key, value string) error {
+	var err error
+	switch key {
+	case "term":
+		opts.termMode, err = strconv.ParseBool(value)
+	case "code_style":
+		opts.codeStyle = value
+	case "width":
+		opts.width, err = strconv.Atoi(value)
+		if err == nil && opts.width < 0 {
+			err = errors.New("must not be negative")
+		}
+	case "pager":
+		opts.pager = value
+	case "style":
+		opts.style = value
+	case "theme":
+		opts.theme = value
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+// unquote strips surrounding quotes, or a trailing # comment from a bare value.
+func unquote(value string) string {
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --- Diff ---
+
+// diffContext is how many unchanged lines are shown around each change.
+const diffContext = 3
+
+type diffLine struct {
+	op           byte // ' ', '+' or '-'
+	oldNo, newNo int  // line numbers, 0 where the line does not exist
+	text         string
+}
+
+// runDiff compares two markdown files line by line and shows the result in
+// the reader, in the terminal with -t, or in a file with --output.
+func runDiff(args []string, opts options) error {
+	if len(args) != 2 {
+		return errors.New("usage: marko diff <old.md> <new.md>")
+	}
+	var sources [2][]string
+	for i, name := range args {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		sources[i] = splitLines(data)
+	}
+	lines := diffLines(sources[0], sources[1])
+
+	if opts.termMode {
+		output(renderDiffTerm(lines, opts), opts)
+		return nil
+	}
+
+	title := filepath.Base(args[0]) + " → " + filepath.Base(args[1])
+	p := page{title: title, body: renderDiff(args[0], args[1], lines)}
+	switch opts.output {
+	case "":
+	case "-":
+		_, err := fmt.Print(readerPage(p, opts))
+		return err
+	default:
+		return os.WriteFile(opts.output, []byte(readerPage(p, opts)), 0o644)
+	}
+
+	ln, url, err := listen(opts)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, readerPage(p, opts))
+	})
+	return serveReader(&http.Server{Handler: mux}, mux, ln, url, opts)
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script from a to b with Myers'
+// algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace[d] holds v for k in [-d, d] after d edits, indexed by k+d.
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+
+	var script []diffLine
+	x, y := n, m
+	for d := len(trace); d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, diffLine{op: ' ', text: a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			script = append(script, diffLine{op: '+', text: b[y-1]})
+			y--
+		} else {
+			script = append(script, diffLine{op: '-', text: a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		script = append(script, diffLine{op: ' ', text: a[x-1]})
+		x, y = x-1, y-1
+	}
+	slices.Reverse(script)
+
+	oldNo, newNo := 0, 0
+	for i := range script {
+		if script[i].op != '+' {
+			oldNo++
+			script[i].oldNo = oldNo
+		}
+		if script[i].op != '-' {
+			newNo++
+			script[i].newNo = newNo
+		}
+	}
+	return script
+}
+
+// diffVisible marks the lines within diffContext of a change.
+func diffVisible(lines []diffLine) []bool {
+	visible := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for j := max(0, i-diffContext); j <= min(len(lines)-1, i+diffContext); j++ {
+			visible[j] = true
+		}
+	}
+	return visible
+}
+
+func diffCounts(lines []diffLine) (added, removed int) {
+	for _, l := range lines {
+		switch l.op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// renderDiff lays out the changes as a unified diff table, with unchanged
+// stretches folded away.
+func renderDiff(oldName, newName string, lines []diffLine) string {
+	added, removed := diffCounts(lines)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s → %s</h1>\n", html.EscapeString(oldName), html.EscapeString(newName))
+	if added+removed == 0 {
+		b.WriteString("<p>The files are identical.</p>\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "<p class=\"diff-stat\"><span class=\"diff-added\">+%d</span> <span class=\"diff-removed\">−%d</span></p>\n", added, removed)
+
+	visible := diffVisible(lines)
+	b.WriteString("<table class=\"diff\">\n")
+	for i := 0; i < len(lines); i++ {
+		if !visible[i] {
+			j := i
+			for j < len(lines) && !visible[j] {
+				j++
+			}
+			fmt.Fprintf(&b, "<tr class=\"diff-gap\"><td colspan=\"3\">⋯ %d unchanged lines</td></tr>\n", j-i)
+			i = j - 1
+			continue
+		}
+
+		l := lines[i]
+		class := ""
+		switch l.op {
+		case '+':
+			class = ` class="diff-add"`
+		case '-':
+			class = ` class="diff-del"`
+		}
+		fmt.Fprintf(&b, "<tr%s><td class=\"diff-num\">%s</td><td class=\"diff-num\">%s</td><td>%c %s</td></tr>\n",
+			class, lineNumber(l.oldNo), lineNumber(l.newNo), l.op, html.EscapeString(l.text))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+func lineNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// renderDiffTerm prints the changes as a unified diff, in color unless
+// --no-color is set.
+func renderDiffTerm(lines []diffLine, opts options) string {
+	color := func(code, s string) string {
+		if opts.noColor {
+			return s
+		}
+		return "\x1b[" + code + "m" + s + "\x1b[0m"
+	}
+
+	added, removed := diffCounts(lines)
+	if added+removed == 0 {
+		return "The files are identical.\n"
+	}
+
+	var b strings.Builder
+	visible := diffVisible(lines)
+	for i := 0; i < len(lines); i++ {
+		if !visible[i] {
+			j := i
+			for j < len(lines) && !visible[j] {
+				j++
+			}
+			b.WriteString(color("2", fmt.Sprintf("⋯ %d unchanged lines", j-i)) + "\n")
+			i = j - 1
+			continue
+		}
+		l := lines[i]
+		line := string(l.op) + " " + l.text
+		switch l.op {
+		case '+':
+			line = color("32", line)
+		case '-':
+			line = color("31", line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// --- Input encoding ---
+
+// inputEncoding looks up an --encoding name such as latin1, windows-1252,
+// shift_jis or utf-16le.
+func inputEncoding(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q (try e.g. latin1, windows-1252, utf-16le)", name)
+	}
+	return enc, nil
+}
+
+// decodeInput converts md to UTF-8. Text starting with a UTF-16 byte order
+// mark is decoded as UTF-16; anything else is decoded with --encoding, or
+// left alone when it is not set.
+func decodeInput(md []byte, opts options) ([]byte, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(md, []byte{0xff, 0xfe}), bytes.HasPrefix(md, []byte{0xfe, 0xff}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case opts.encoding != "":
+		var err error
+		if enc, err = inputEncoding(opts.encoding); err != nil {
+			return nil, err
+		}
+	default:
+		return md, nil
+	}
+	return enc.NewDecoder().Bytes(md)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// --- Export ---
+
+// exportHTML writes the standalone reader page for doc to opts.output, or
+// to stdout when the output path is "-".
+func exportHTML(doc document, opts options) error {
+	page := readerPage(newReaderDoc(doc, "/", opts).page(), opts)
+	if opts.minify {
+		page = minifyHTML(page)
+	}
+
+	if opts.output == "-" {
+		_, err := fmt.Print(page)
+		return err
+	}
+	return os.WriteFile(opts.output, []byte(page), 0o644)
+}
+
+// renderPDF prints the reader page to opts.pdf through whichever converter
+// is installed. The light color scheme is always used.
+func renderPDF(doc document, opts options) error {
+	p := newReaderDoc(doc, "/", opts).page()
+	p.light = true
+
+	tmp, err := os.CreateTemp("", "marko-*.html")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(readerPage(p, opts)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	out, err := filepath.Abs(opts.pdf)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := pdfCommand(tmp.Name(), out)
+	if err != nil {
+		return err
+	}
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w\n%s", filepath.Base(cmd.Path), err, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func pdfCommand(htmlPath, pdfPath string) (*exec.Cmd, error) {
+	if path, err := exec.LookPath("wkhtmltopdf"); err == nil {
+		return exec.Command(path, "--quiet", "--enable-local-file-access", htmlPath, pdfPath), nil
+	}
+
+	var browsers []string
+	switch runtime.GOOS {
+	case "darwin":
+		browsers = []string{
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		}
+	case "windows":
+		browsers = []string{"chrome", "msedge"}
+	default:
+		browsers = []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser"}
+	}
+
+	fileURL := "file://" + filepath.ToSlash(htmlPath)
+	if !strings.HasPrefix(filepath.ToSlash(htmlPath), "/") {
+		fileURL = "file:///" + filepath.ToSlash(htmlPath)
+	}
+
+	for _, name := range browsers {
+		if path, err := exec.LookPath(name); err == nil {
+			return exec.Command(path, "--headless", "--disable-gpu", "--no-pdf-header-footer",
+				"--print-to-pdf="+pdfPath, fileURL), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no PDF converter found: install wkhtmltopdf or Google Chrome/Chromium")
+}
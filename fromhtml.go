@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// --- HTML input ---
+
+// looksLikeHTML reports whether input is an HTML page rather than markdown
+// that happens to contain some tags.
+func looksLikeHTML(md []byte) bool {
+	head := bytes.TrimLeft(bytes.TrimPrefix(md, []byte("\xef\xbb\xbf")), " \t\r\n")
+	head = bytes.ToLower(head[:min(len(head), 14)])
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+var (
+	blankLines = regexp.MustCompile(`\n{3,}`)
+	spaceLines = regexp.MustCompile(`(?m)^[ \t]+$`)
+	spaces     = regexp.MustCompile(`\s+`)
+)
+
+// htmlToMarkdown converts the common elements of an HTML page (headings,
+// paragraphs, emphasis, links, images, lists, quotes, code and tables) to
+// markdown. Anything else contributes only its text.
+func htmlToMarkdown(src []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	md := spaceLines.ReplaceAllString(htmlChildren(doc), "")
+	md = blankLines.ReplaceAllString(md, "\n\n")
+	return []byte(strings.TrimSpace(md) + "\n"), nil
+}
+
+func htmlChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(htmlNode(c))
+	}
+	return b.String()
+}
+
+func htmlNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaces.ReplaceAllString(n.Data, " ")
+	case html.DocumentNode:
+		return htmlChildren(n)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	inner := func() string { return strings.TrimSpace(htmlChildren(n)) }
+	block := func(s string) string { return "\n\n" + s + "\n\n" }
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template:
+		return ""
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return block(strings.Repeat("#", level) + " " + inner())
+	case atom.P:
+		return block(inner())
+	case atom.Br:
+		return "  \n"
+	case atom.Hr:
+		return block("---")
+	case atom.Strong, atom.B:
+		return wrapInline(inner(), "**")
+	case atom.Em, atom.I:
+		return wrapInline(inner(), "*")
+	case atom.Del, atom.S:
+		return wrapInline(inner(), "~~")
+	case atom.Code:
+		return wrapInline(htmlText(n), "`")
+	case atom.A:
+		href := htmlAttr(n, "href")
+		if href == "" {
+			return inner()
+		}
+		return "[" + inner() + "](" + href + ")"
+	case atom.Img:
+		return "![" + htmlAttr(n, "alt") + "](" + htmlAttr(n, "src") + ")"
+	case atom.Pre:
+		return block(htmlPre(n))
+	case atom.Blockquote:
+		return block(prefixLines(strings.TrimSpace(blankLines.ReplaceAllString(htmlChildren(n), "\n\n")), "> "))
+	case atom.Ul, atom.Ol:
+		return block(htmlList(n))
+	case atom.Table:
+		return block(htmlTable(n))
+	case atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Nav, atom.Aside, atom.Figure:
+		return block(inner())
+	}
+	return htmlChildren(n)
+}
+
+func wrapInline(s, mark string) string {
+	if s == "" {
+		return ""
+	}
+	return mark + s + mark
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func htmlText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(htmlText(c))
+	}
+	return b.String()
+}
+
+// htmlPre turns <pre> into a fenced block, taking the language from a
+// "language-x" class on its <code>.
+func htmlPre(n *html.Node) string {
+	var lang string
+	if code := n.FirstChild; code != nil && code.DataAtom == atom.Code {
+		for _, class := range strings.Fields(htmlAttr(code, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+			}
+		}
+	}
+	return "```" + lang + "\n" + strings.TrimRight(htmlText(n), "\n") + "\n```"
+}
+
+func htmlList(n *html.Node) string {
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(len(items)+1) + ". "
+		}
+		body := strings.TrimSpace(blankLines.ReplaceAllString(htmlChildren(c), "\n\n"))
+		body = strings.ReplaceAll(body, "\n\n", "\n")
+		items = append(items, marker+strings.ReplaceAll(body, "\n", "\n"+strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func htmlTable(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					text := strings.TrimSpace(htmlChildren(cell))
+					row = append(row, strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "|", `\|`))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// --- Frontmatter ---
+
+// frontmatter is a metadata block fenced by --- (YAML) or +++ (TOML) at the
+// very start of a document.
+type frontmatter struct {
+	format string // "yaml" or "toml", empty when there is no frontmatter
+	raw    []byte // the lines between the fences
+}
+
+// stripFrontmatter splits a leading frontmatter block from the markdown
+// body. Documents without one are returned unchanged. A block only counts
+// when it starts right after the fence and holds a YAML or TOML mapping,
+// so a document opening with a --- thematic break keeps its text.
+func stripFrontmatter(md []byte) (frontmatter, []byte) {
+	src := bytes.TrimPrefix(md, []byte("\ufeff"))
+
+	first, rest, ok := bytes.Cut(src, []byte("\n"))
+	if !ok {
+		return frontmatter{}, md
+	}
+
+	var fm frontmatter
+	fence := string(bytes.TrimSpace(first))
+	switch fence {
+	case "---":
+		fm.format = "yaml"
+	case "+++":
+		fm.format = "toml"
+	default:
+		return frontmatter{}, md
+	}
+
+	if next, _, _ := bytes.Cut(rest, []byte("\n")); len(bytes.TrimSpace(next)) == 0 {
+		return frontmatter{}, md
+	}
+
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		end := min(offset+len(line)+1, len(rest))
+
+		closing := string(bytes.TrimSpace(line))
+		if closing == fence || (fm.format == "yaml" && closing == "...") {
+			fm.raw = rest[:offset]
+			if data, err := fm.data(); err != nil || len(data) == 0 {
+				return frontmatter{}, md
+			}
+			return fm, rest[end:]
+		}
+		offset = end
+	}
+	return frontmatter{}, md
+}
+
+// title returns the top-level title value, if any.
+func (fm frontmatter) title() string {
+	sep := ":"
+	if fm.format == "toml" {
+		sep = "="
+	}
+
+	for _, line := range strings.Split(string(fm.raw), "\n") {
+		key, value, ok := strings.Cut(line, sep)
+		if ok && strings.TrimRight(key, " \t") == "title" {
+			return unquote(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// data decodes the frontmatter. Documents without one decode to an empty map.
+func (fm frontmatter) data() (map[string]any, error) {
+	data := make(map[string]any)
+	var err error
+	switch fm.format {
+	case "yaml":
+		err = yaml.Unmarshal(fm.raw, &data)
+	case "toml":
+		err = toml.Unmarshal(fm.raw, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s frontmatter: %w", strings.ToUpper(fm.format), err)
+	}
+	if data == nil {
+		// An empty YAML block decodes to a nil map.
+		data = make(map[string]any)
+	}
+	return data, nil
+}
+
+func printFrontmatter(md []byte) error {
+	fm, _ := stripFrontmatter(md)
+	data, err := fm.data()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestStripFrontmatter(t *testing.T) {
+	tests := []struct {
+		name, md, format, body string
+	}{
+		{"yaml", "---\ntitle: T\n---\n# H\n", "yaml", "# H\n"},
+		{"yaml dots", "---\ntitle: T\n...\n# H\n", "yaml", "# H\n"},
+		{"toml", "+++\ntitle = \"T\"\n+++\n# H\n", "toml", "# H\n"},
+		{"crlf", "---\r\ntitle: T\r\n---\r\n# H\r\n", "yaml", "# H\r\n"},
+		{"thematic break", "---\n\nIntro para\n\n---\n\n# Real\n", "", "---\n\nIntro para\n\n---\n\n# Real\n"},
+		{"not a mapping", "---\nIntro para\n---\n# Real\n", "", "---\nIntro para\n---\n# Real\n"},
+		{"empty block", "---\n---\n# H\n", "", "---\n---\n# H\n"},
+		{"unclosed", "---\ntitle: T\n# H\n", "", "---\ntitle: T\n# H\n"},
+		{"none", "# H\n", "", "# H\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := stripFrontmatter([]byte(tt.md))
+			if fm.format != tt.format || string(body) != tt.body {
+				t.Errorf("stripFrontmatter(%q) = %q, %q, want %q, %q", tt.md, fm.format, body, tt.format, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// --- Search highlighting ---
+
+// ansiEscape matches SGR styling and OSC 8 hyperlink sequences.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m|\x1b\]8;;[^\x1b]*\x1b\\`)
+
+// grepPattern compiles --grep, which ignores case unless --grep-case is set.
+func grepPattern(opts options) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(opts.grep)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+	}
+	if !opts.grepCase {
+		re = regexp.MustCompile("(?i)" + opts.grep)
+	}
+	return re, nil
+}
+
+// highlightMatches shows the rendered lines whose text matches re in
+// reverse video. The styling is reapplied after every reset glamour emits.
+func highlightMatches(rendered string, re *regexp.Regexp) string {
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		if !re.MatchString(ansiEscape.ReplaceAllString(line, "")) {
+			continue
+		}
+		line = strings.ReplaceAll(line, "\x1b[0m", "\x1b[0m\x1b[7m")
+		lines[i] = "\x1b[7m" + line + "\x1b[0m"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --- Close on exit ---
+
+const (
+	// Browsers throttle timers in background tabs to about once a minute,
+	// so a tab is only considered gone well after that.
+	tabTimeout = 90 * time.Second
+	// closeGrace lets a reloading tab reconnect before the reader exits.
+	closeGrace = 3 * time.Second
+)
+
+// heartbeat tracks the reader tabs that are still open. Each tab pings
+// /ping?tab=<id> periodically and sends &bye=1 when it is closed.
+type heartbeat struct {
+	mu   sync.Mutex
+	tabs map[string]time.Time
+	seen bool
+}
+
+func newHeartbeat() *heartbeat {
+	return &heartbeat{tabs: make(map[string]time.Time)}
+}
+
+func (h *heartbeat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tab := r.URL.Query().Get("tab")
+	if tab == "" {
+		http.Error(w, "missing tab", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	if r.URL.Query().Has("bye") {
+		delete(h.tabs, tab)
+	} else {
+		h.tabs[tab] = time.Now()
+		h.seen = true
+	}
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// idle returns a channel that is closed once every tab that has connected
+// is gone.
+func (h *heartbeat) idle() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		var emptySince time.Time
+		for now := range time.Tick(time.Second) {
+			h.mu.Lock()
+			for tab, last := range h.tabs {
+				if now.Sub(last) > tabTimeout {
+					delete(h.tabs, tab)
+				}
+			}
+			empty := h.seen && len(h.tabs) == 0
+			h.mu.Unlock()
+
+			switch {
+			case !empty:
+				emptySince = time.Time{}
+			case emptySince.IsZero():
+				emptySince = now
+			case now.Sub(emptySince) >= closeGrace:
+				close(done)
+				return
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- History ---
+
+// historyLimit is how many files the history remembers.
+const historyLimit = 100
+
+type historyEntry struct {
+	opened time.Time
+	path   string
+}
+
+func historyPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "marko", "history")
+}
+
+// readHistory returns the recorded files, oldest first. Lines that cannot
+// be parsed are skipped.
+func readHistory(path string) []historyEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		stamp, name, ok := strings.Cut(scanner.Text(), "\t")
+		opened, err := time.Parse(time.RFC3339, stamp)
+		if ok && err == nil && name != "" {
+			entries = append(entries, historyEntry{opened, name})
+		}
+	}
+	return entries
+}
+
+// recordHistory adds the files among docs to the history, moving ones
+// already there to the end. Stdin and URLs have no path and are left out.
+// The history is a convenience, so failing to write it is not an error.
+func recordHistory(docs []document) {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+
+	entries := readHistory(path)
+	now := time.Now()
+	for _, doc := range docs {
+		if doc.path == "" {
+			continue
+		}
+		name, err := filepath.Abs(doc.path)
+		if err != nil {
+			continue
+		}
+		entries = slices.DeleteFunc(entries, func(e historyEntry) bool { return e.path == name })
+		entries = append(entries, historyEntry{now, name})
+	}
+	entries = entries[max(0, len(entries)-historyLimit):]
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s\t%s\n", e.opened.Format(time.RFC3339), e.path)
+	}
+	if os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+		os.WriteFile(path, []byte(b.String()), 0o600)
+	}
+}
+
+// printRecent lists the files in the history, newest first.
+func printRecent() {
+	entries := readHistory(historyPath())
+	for _, e := range slices.Backward(entries) {
+		fmt.Printf("%s  %s\n", e.opened.Local().Format("2006-01-02 15:04"), e.path)
+	}
+}
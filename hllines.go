@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Highlighted lines ---
+
+// highlightLinesAttr holds the line ranges of a code block to emphasize.
+var highlightLinesAttr = []byte("marko-highlight-lines")
+
+// highlightLinesExtension reads a {2,4-6} suffix on a fenced code block's
+// info string. highlightLinesOptions passes the ranges on to chroma.
+type highlightLinesExtension struct{}
+
+func (highlightLinesExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(highlightLinesTransformer{}, 100)))
+}
+
+type highlightLinesTransformer struct{}
+
+func (highlightLinesTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok || block.Info == nil {
+			return ast.WalkContinue, nil
+		}
+		info := block.Info.Segment.Value(source)
+		if i := bytes.IndexByte(info, '{'); i >= 0 {
+			if ranges := parseLineRanges(string(info[i:])); ranges != nil {
+				block.SetAttribute(highlightLinesAttr, ranges)
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// parseLineRanges parses "{2,4-6}" into line ranges. Anything malformed
+// yields nil so the block is highlighted as usual.
+func parseLineRanges(s string) [][2]int {
+	s, ok := strings.CutPrefix(strings.TrimSpace(s), "{")
+	if !ok {
+		return nil
+	}
+	if s, ok = strings.CutSuffix(s, "}"); !ok {
+		return nil
+	}
+
+	var ranges [][2]int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil || from < 1 {
+			return nil
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return nil
+			}
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges
+}
+
+func highlightLinesOptions(c highlighting.CodeBlockContext) []chromahtml.Option {
+	if c.Attributes() == nil {
+		return nil
+	}
+	if v, ok := c.Attributes().Get(highlightLinesAttr); ok {
+		if ranges, ok := v.([][2]int); ok {
+			return []chromahtml.Option{chromahtml.HighlightLines(ranges)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// --- Includes ---
+
+// maxIncludeDepth bounds how deeply {{include}} directives may nest.
+const maxIncludeDepth = 10
+
+// includeDirective matches a line consisting of {{include path}}.
+var includeDirective = regexp.MustCompile(`^\s*\{\{\s*include\s+(.+?)\s*\}\}\s*$`)
+
+// expandIncludes replaces {{include other.md}} lines with the contents of
+// the named file, resolved relative to baseDir. Included files may include
+// others in turn. Directives inside fenced code blocks are left alone.
+func expandIncludes(md []byte, baseDir string) ([]byte, error) {
+	return expandIncludesFrom(md, baseDir, nil)
+}
+
+// expandIncludesFrom does the work of expandIncludes. stack holds the
+// absolute paths of the files currently being expanded.
+func expandIncludesFrom(md []byte, baseDir string, stack []string) ([]byte, error) {
+	var out bytes.Buffer
+	var fence []byte
+	for line := range bytes.Lines(md) {
+		trimmed := bytes.TrimLeft(line, " ")
+		switch {
+		case fence != nil:
+			if bytes.HasPrefix(trimmed, fence) {
+				fence = nil
+			}
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			fence = trimmed[:3]
+		default:
+			if m := includeDirective.FindSubmatch(line); m != nil {
+				included, err := includeFile(filepath.Join(baseDir, string(m[1])), stack)
+				if err != nil {
+					return nil, err
+				}
+				out.Write(included)
+				if len(included) > 0 && !bytes.HasSuffix(included, []byte("\n")) {
+					out.WriteByte('\n')
+				}
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	return out.Bytes(), nil
+}
+
+func includeFile(name string, stack []string) ([]byte, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("include cycle through %s", name)
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, fmt.Errorf("cannot include %s: includes nested more than %d deep", name, maxIncludeDepth)
+	}
+
+	md, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot include %s: %w", name, err)
+	}
+	_, md = stripFrontmatter(md)
+	return expandIncludesFrom(md, filepath.Dir(name), append(stack, abs))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- Latest file ---
+
+// latestMarkdown returns the most recently modified markdown file in dir,
+// looking into subfolders when recursive is set. Hidden folders such as
+// .git are skipped.
+func latestMarkdown(dir string, recursive bool) (string, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var newest string
+	var newestTime time.Time
+	filepath.WalkDir(dir, func(name string, e fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case e.IsDir() && name != dir && (!recursive || strings.HasPrefix(e.Name(), ".")):
+			return filepath.SkipDir
+		case e.IsDir() || !slices.Contains(markdownExts, strings.ToLower(filepath.Ext(name))):
+			return nil
+		}
+		info, err := e.Info()
+		if err == nil && info.ModTime().After(newestTime) {
+			newest, newestTime = name, info.ModTime()
+		}
+		return nil
+	})
+
+	if newest == "" {
+		return "", fmt.Errorf("no markdown files in %s", dir)
+	}
+	return newest, nil
+}
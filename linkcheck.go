@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Link checking ---
+
+const (
+	defaultCheckJobs = 8
+	checkTimeout     = 10 * time.Second
+)
+
+type linkResult struct {
+	url    string
+	status int
+	err    error
+}
+
+func (r linkResult) broken() bool {
+	return r.err != nil || r.status >= 400
+}
+
+// checkLinks requests every distinct http(s) link and image of the document
+// and prints its status. It fails if any of them is broken.
+func checkLinks(md []byte, opts options) error {
+	o := collectOutline(md, opts)
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(url string) {
+		if (strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	for _, l := range o.Links {
+		add(l.URL)
+	}
+	for _, img := range o.Images {
+		add(img.URL)
+	}
+
+	client := &http.Client{Timeout: checkTimeout}
+	results := make([]linkResult, len(urls))
+	jobs := make(chan struct{}, opts.jobs)
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		jobs <- struct{}{}
+		go func() {
+			defer wg.Done()
+			results[i] = checkLink(client, url)
+			<-jobs
+		}()
+	}
+	wg.Wait()
+
+	broken := 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			fmt.Printf("ERR  %s (%s)\n", r.url, r.err)
+		default:
+			fmt.Printf("%d  %s\n", r.status, r.url)
+		}
+		if r.broken() {
+			broken++
+		}
+	}
+	if broken > 0 {
+		return fmt.Errorf("%d of %d links are broken", broken, len(urls))
+	}
+	return nil
+}
+
+// checkLink tries a HEAD request first, then GET, since some servers do not
+// answer HEAD properly.
+func checkLink(client *http.Client, url string) linkResult {
+	r := linkRequest(client, http.MethodHead, url)
+	if r.broken() {
+		r = linkRequest(client, http.MethodGet, url)
+	}
+	return r
+}
+
+func linkRequest(client *http.Client, method, url string) linkResult {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return linkResult{url: url, err: err}
+	}
+	req.Header.Set("User-Agent", "marko/"+version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return linkResult{url: url, err: err}
+	}
+	resp.Body.Close()
+	return linkResult{url: url, status: resp.StatusCode}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// liveRender prints the rendered document and renders it again at the new
+// width whenever the terminal is resized, until Ctrl+C.
+func liveRender(md []byte, opts options) error {
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	for {
+		rendered, err := render(md, terminalWidth(), opts)
+		if err != nil {
+			return fmt.Errorf("render failed: %w", err)
+		}
+		fmt.Print("\033[H\033[2J" + rendered)
+
+		select {
+		case <-resize:
+		case <-interrupt:
+			return nil
+		}
+	}
+}
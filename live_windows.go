@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// liveRender is not available on Windows, which has no resize signal.
+func liveRender(md []byte, opts options) error {
+	return errors.New("--live is not supported on Windows")
+}
@@ -2,234 +2,1755 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"io/fs"
+	"maps"
+	"mime"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/charmbracelet/glamour"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
 	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
-	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/renderer"
+	mdhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"golang.org/x/term"
 )
 
 const version = "0.2.0"
 
+const defaultTitle = "marko reader"
+
+const defaultCodeStyle = "dracula"
+
+const fetchTimeout = 30 * time.Second
+
+var errTooManyArgs = errors.New("too many arguments (expected 1 file)")
+
 const usage = `marko — a terminal markdown reader
 
 Usage:
   marko <file.md>       Open in visual reader (default)
+  marko <a.md> <b.md>   Open several files with a sidebar
   marko -t <file.md>    Render markdown in terminal
+  marko -t <a.md> <b.md>
+                        Render several files in terminal as one document
+  marko --serve <dir>   Browse all markdown files under a directory
+  marko --latest <dir>  Open the most recently modified markdown file in a
+                        directory (--recursive to include subfolders)
+  marko --recent        List recently opened files, newest first
+  marko <url>           Fetch markdown over http(s)
   marko -               Read from stdin
   cat file | marko      Pipe markdown to stdin
+  marko diff <old.md> <new.md>
+                        Show the changes between two files (-t for terminal)
+  marko completion <shell>
+                        Print a bash, zsh or fish completion script
+
+Options:
+  -t, --term    Render in terminal instead of visual reader
+  --style <name>
+                Terminal style or JSON style file, even when piped
+                (overrides GLAMOUR_STYLE)
+  --width <n>   Wrap terminal output at n columns (0 disables wrapping)
+  --wrap <auto|none>
+                Wrap at the terminal width up to 120 columns (default), or
+                not at all, for ASCII art and other preformatted text; wide
+                tables then overflow narrow terminals
+  --grep <pattern>
+                Highlight terminal output lines matching a regular expression
+  --grep-case   Make --grep case-sensitive
+  --osc8        Make links clickable in terminals that support OSC 8
+                hyperlinks (iTerm2, kitty, WezTerm, ...)
+  --show-urls   Print link targets in parentheses after the link text
+  --live        With -t, render again whenever the terminal is resized
+  --paginate-by-heading
+                With -t, show one section at a time and wait for Enter
+                before the next
+  --paginate-depth <n>
+                Heading level --paginate-by-heading splits at (default: the
+                highest level in the document)
+  --raw         Page through the markdown source without rendering it
+  --no-color    Render terminal output as plain text without ANSI colors
+  --no-emoji    Leave :shortcodes: as text instead of emoji
+  --no-pager    Print terminal output directly, even when it is long
+  --pager-always
+                Page terminal output even when it fits on the screen
+  --close-on-exit
+                Stop the visual reader once all its browser tabs are closed
+  --no-open     Print the reader URL without launching a browser
+  -q, --quiet   Print nothing while the reader runs, except its URL (or
+                socket) alone when no browser is opened
+  --open-with <cmd>
+                Open the reader with this browser command instead of the
+                system default
+  --host <addr> Listen on this address (default: 127.0.0.1, 0.0.0.0 for all)
+  --port <n>    Serve the visual reader on a fixed port (default: random)
+  --unix <path> Serve the visual reader on a Unix socket instead of TCP, for
+                access control by file permissions (no browser is opened)
+  -w, --watch   Reload the visual reader when the file changes
+  --base <dir>  Resolve relative images, links and includes of stdin input
+                against dir
+  --reload-transport <sse|ws>
+                How --watch pushes updates: server-sent events (default) or
+                a WebSocket, which some proxies handle better
+  --reload-debounce <duration>
+                Wait for changes to settle this long before reloading, so a
+                burst of saves renders once (default: 150ms)
+  --follow-links
+                Render linked local markdown files in the visual reader
+  --reader-width <px>
+                Maximum width of the reader text column (default: 720)
+  --columns <n> Flow the reader text in 1 to 3 columns, each up to
+                --reader-width wide; fewer on narrow windows (default: 1)
+  --reader-font <serif|sans|mono>
+                Typeface of the reader text (default: sans)
+  --toc         Show a table of contents in the visual reader, with the
+                section being read highlighted
+  --toc-depth <n>
+                Deepest heading level in the table of contents, 1 to 6
+                (default: 3)
+  --title <text>
+                Page title of the visual reader and HTML export, instead of
+                the frontmatter title or first heading
+  -o, --output <file.html>
+                Write the reader page to a file instead (- for stdout)
+  --minify      Strip needless whitespace from the written page
+  --copy        Copy the rendered HTML (terminal output with -t) to the
+                clipboard instead
+  --pdf <file.pdf>
+                Write the document as PDF (needs wkhtmltopdf or Chrome)
+  --math        Render $inline$ and $$display$$ TeX math with KaTeX
+  --mermaid     Draw mermaid code blocks as diagrams
+  --collapse-code
+                Fold long code blocks in the visual reader, showing their
+                first lines until expanded
+  --theme <name>
+                Reader color theme: github-light, github-dark, dracula,
+                solarized, or custom (colors come from --css)
+  --css <file>  Add a stylesheet to the visual reader
+  --css-replace <file>
+                Use a stylesheet instead of the built-in one
+  --template <file>
+                Build reader pages from a Go text/template using {{.Title}},
+                {{.Body}}, {{.TOC}} and {{.Style}}
+  --line-numbers
+                Number the lines of code blocks in the visual reader
+  --no-highlight
+                Leave code blocks in the visual reader uncolored
+  --twemoji     Show emoji in the visual reader as Twemoji images (online)
+  --tab-size <n>
+                Width of tabs in code blocks (default: 4)
+  --code-style <name>
+                Syntax highlighting style for the visual reader (default:
+                dracula); dark styles switch to github in the light theme
+  --safe        Drop raw HTML and script links from untrusted documents
+                (HTML blocks are left out of the reader page)
+  --force       Read files that look binary or are not markdown
+  --from-html   Convert HTML input to markdown before rendering
+  --encoding <name>
+                Character encoding of the input, e.g. latin1 (default: UTF-8,
+                or UTF-16 when the input starts with a byte order mark)
+  --section <heading>
+                Show only the section under a heading (case-insensitive)
+  --since <ref> Show only the lines added to a file since a git commit,
+                branch or tag
+  --timeout <duration>
+                Give up reading a URL or stdin after this long, e.g. 10s
+                (default: 30s for URLs, none for stdin)
+  --print-title Print the document title and exit (status 1 if none)
+  --check-links Check that the http(s) links respond and exit (status 1 if not)
+  --jobs <n>    Links to check in parallel (default: 8)
+  --front-matter-json
+                Print the YAML or TOML frontmatter as JSON and exit
+  --toc-only    Print the heading outline with anchor ids and exit
+  --list-headings
+                Print every heading as line:level:text and exit, e.g. for an
+                editor's quickfix list
+  --json        Print the headings, links, images and code blocks as JSON
+  --summary     Print the first paragraph as plain text and exit
+  --stats       Print word, heading, code block and link counts and exit
+  --style-list  List terminal and code style names
+  --help        Show this help
+  --version     Show version and build details (--short for the number only)
+
+Environment:
+  GLAMOUR_STYLE   Set terminal rendering style (dark, light, notty, dracula, ascii)
+  COLUMNS         Terminal width to use when it cannot be detected
+  PAGER           Set pager command (default: less -R -F -X)
+  MARKO_BROWSER   Set the browser command for the visual reader (like --open-with)
+  MARKO_CODE_STYLE
+                  Set the default syntax highlighting style
+  XDG_CONFIG_HOME
+                  Config is read from $XDG_CONFIG_HOME/marko/config.toml
+                  (default: ~/.config/marko/config.toml)
+  XDG_STATE_HOME  The --recent history is kept in $XDG_STATE_HOME/marko/history
+                  (default: ~/.local/state/marko/history, last 100 files)`
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "marko: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	termMode        bool
+	watch           bool
+	reloadTransport string
+	reloadDebounce  time.Duration
+	toc             bool
+	tocDepth        int
+	tocOnly         bool
+	listHeadings    bool
+	readerWidth     int
+	columns         int
+	readerFont      string
+	title           string
+	followLinks     bool
+	base            string
+	serveDir        string
+	latest          string
+	recent          bool
+	recursive       bool
+	codeStyle       string
+	output          string
+	minify          bool
+	pdf             string
+	copy            bool
+	math            bool
+	mermaid         bool
+	collapseCode    bool
+	lineNums        bool
+	noHighlight     bool
+	inlineStyles    bool // chroma inline styles, for HTML used without the reader stylesheet
+	twemoji         bool
+	tabSize         int
+	width           int    // terminal wrap width, -1 to detect, 0 for no wrapping
+	pager           string // pager command for long terminal output
+	style           string // glamour style name or path, empty for auto
+	noPager         bool
+	pagerAlways     bool
+	noColor         bool
+	noEmoji         bool
+	raw             bool
+	live            bool
+	paginate        bool
+	paginateDepth   int
+	grep            string
+	grepCase        bool
+	osc8            bool
+	showURLs        bool
+	force           bool
+	fromHTML        bool
+	encoding        string
+	section         string
+	since           string
+	timeout         time.Duration
+	safe            bool
+	styleList       bool
+	printTitle      bool
+	stats           bool
+	summary         bool
+	json            bool
+	frontmatterJSON bool
+	checkLinks      bool
+	jobs            int
+	closeOnExit     bool
+	noOpen          bool
+	quiet           bool
+	openWith        string
+	host            string
+	port            int
+	unixSocket      string
+	theme           string // reader palette, empty to follow the OS
+	cssFile         string
+	cssReplace      bool
+	css             string // contents of cssFile
+	templateFile    string
+	pageTemplate    *template.Template // parsed templateFile
+}
+
+// document is a markdown source. path is empty when it was read from stdin.
+type document struct {
+	path string
+	base string // directory for relative paths when there is no path (--base)
+	md   []byte
+}
+
+// dir returns the directory that relative links, images and includes in
+// the document resolve against, or "" if there is none.
+func (doc document) dir() string {
+	if doc.path != "" {
+		return filepath.Dir(doc.path)
+	}
+	return doc.base
+}
+
+func run() error {
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		return printCompletion(os.Args[2:])
+	}
+
+	opts, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	args := os.Args[1:]
+	diff := len(args) > 0 && args[0] == "diff"
+	if diff {
+		args = args[1:]
+	}
+	opts, args, err = parseFlags(args, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.styleList {
+		printStyleList()
+		return nil
+	}
+
+	if opts.cssFile != "" {
+		css, err := os.ReadFile(opts.cssFile)
+		if err != nil {
+			return fmt.Errorf("failed to read stylesheet: %w", err)
+		}
+		opts.css = string(css)
+	}
+	if opts.templateFile != "" {
+		if opts.pageTemplate, err = loadTemplate(opts.templateFile); err != nil {
+			return err
+		}
+	}
+
+	if diff {
+		return runDiff(args, opts)
+	}
+
+	if opts.serveDir != "" {
+		if len(args) > 0 {
+			return errors.New("--serve takes a directory, not files")
+		}
+		return serveDir(opts.serveDir, opts)
+	}
+
+	if opts.recent {
+		if len(args) > 0 {
+			return errors.New("--recent takes no files")
+		}
+		printRecent()
+		return nil
+	}
+
+	if opts.latest != "" {
+		if len(args) > 0 {
+			return errors.New("--latest takes a directory, not files")
+		}
+		newest, err := latestMarkdown(opts.latest, opts.recursive)
+		if err != nil {
+			return err
+		}
+		args = []string{newest}
+	}
+
+	// Line numbers refer to the file as written, so --list-headings reads it
+	// without expanding includes or cutting it down.
+	if opts.listHeadings {
+		if opts.section != "" || opts.since != "" {
+			return errors.New("--list-headings cannot be used with --section or --since")
+		}
+		docs, err := readInput(args, opts)
+		if err != nil {
+			return err
+		}
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		lines, err := headingLines(docs[0], opts)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	docs, err := getInput(args, opts)
+	if err != nil {
+		return err
+	}
+	recordHistory(docs)
+	if opts.title != "" && len(docs) > 1 && !opts.termMode {
+		return errors.New("--title cannot be used with multiple files")
+	}
+
+	if opts.printTitle {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		title := findTitle(docs[0].md)
+		if title == "" {
+			os.Exit(1)
+		}
+		fmt.Println(title)
+		return nil
+	}
+
+	if opts.checkLinks {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		_, md := stripFrontmatter(docs[0].md)
+		return checkLinks(md, opts)
+	}
+
+	if opts.tocOnly {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		_, md := stripFrontmatter(docs[0].md)
+		return printTOC(md, opts)
+	}
+
+	if opts.frontmatterJSON {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		return printFrontmatter(docs[0].md)
+	}
+
+	if opts.json {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		return printOutline(docs[0].md, opts)
+	}
+
+	if opts.summary {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		_, md := stripFrontmatter(docs[0].md)
+		fmt.Println(summary(md, opts))
+		return nil
+	}
+
+	if opts.stats {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		printStats(docs[0].md, opts)
+		return nil
+	}
+
+	if opts.raw {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		output(string(docs[0].md), opts)
+		return nil
+	}
+
+	if opts.copy {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		_, md := stripFrontmatter(docs[0].md)
+		if !opts.termMode {
+			// The copy is pasted without the reader stylesheet.
+			opts.inlineStyles = true
+			return copyToClipboard(renderHTML(md, opts))
+		}
+		width := opts.width
+		if width < 0 {
+			width = terminalWidth()
+		}
+		rendered, err := render(md, width, opts)
+		if err != nil {
+			return fmt.Errorf("render failed: %w", err)
+		}
+		return copyToClipboard(rendered)
+	}
+
+	if opts.output != "" || opts.pdf != "" {
+		if len(docs) > 1 {
+			return errTooManyArgs
+		}
+		if opts.pdf != "" {
+			return renderPDF(docs[0], opts)
+		}
+		return exportHTML(docs[0], opts)
+	}
+
+	if opts.termMode {
+		width := opts.width
+		if width < 0 {
+			width = terminalWidth()
+		}
+		md := joinDocuments(docs)
+		if opts.live && stdoutIsTTY() {
+			return liveRender(md, opts)
+		}
+		if opts.paginate {
+			return paginateSections(md, width, opts)
+		}
+		rendered, err := render(md, width, opts)
+		if err != nil {
+			return fmt.Errorf("render failed: %w", err)
+		}
+		output(rendered, opts)
+		return nil
+	}
+
+	return openReader(docs, opts)
+}
+
+// joinDocuments concatenates the documents, without their frontmatter, with
+// a horizontal rule between them.
+func joinDocuments(docs []document) []byte {
+	var joined []byte
+	for i, doc := range docs {
+		if i > 0 {
+			joined = append(joined, "\n\n---\n\n"...)
+		}
+		_, md := stripFrontmatter(doc.md)
+		joined = append(joined, md...)
+	}
+	return joined
+}
+
+func parseFlags(args []string, opts options) (options, []string, error) {
+	var remaining []string
+	var err error
+
+	args, inline := splitFlagValues(args)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		start := i
+		switch arg {
+		case "-t", "--term":
+			opts.termMode = true
+		case "--safe":
+			opts.safe = true
+		case "--force":
+			opts.force = true
+		case "--from-html":
+			opts.fromHTML = true
+		case "--encoding":
+			if opts.encoding, err = flagValue(args, &i); err == nil {
+				_, err = inputEncoding(opts.encoding)
+			}
+		case "--section":
+			opts.section, err = flagValue(args, &i)
+		case "--since":
+			opts.since, err = flagValue(args, &i)
+		case "--timeout":
+			opts.timeout, err = durationValue(args, &i)
+		case "--print-title":
+			opts.printTitle = true
+		case "--check-links":
+			opts.checkLinks = true
+		case "--jobs":
+			opts.jobs, err = positiveValue(args, &i)
+		case "--front-matter-json":
+			opts.frontmatterJSON = true
+		case "--toc-only":
+			opts.tocOnly = true
+		case "--list-headings":
+			opts.listHeadings = true
+		case "--json":
+			opts.json = true
+		case "--summary":
+			opts.summary = true
+		case "--stats":
+			opts.stats = true
+		case "--style":
+			if opts.style, err = flagValue(args, &i); err == nil {
+				err = validateStyle(opts.style)
+			}
+		case "--style-list":
+			opts.styleList = true
+		case "--width":
+			opts.width, err = widthValue(args, &i)
+		case "--wrap":
+			var value string
+			if value, err = flagValue(args, &i); err == nil {
+				switch value {
+				case "auto":
+					opts.width = -1
+				case "none":
+					opts.width = 0
+				default:
+					err = fmt.Errorf("invalid --wrap %q (expected auto or none)", value)
+				}
+			}
+		case "--grep":
+			opts.grep, err = flagValue(args, &i)
+		case "--grep-case":
+			opts.grepCase = true
+		case "--osc8":
+			opts.osc8 = true
+		case "--show-urls":
+			opts.showURLs = true
+		case "--live":
+			opts.live = true
+		case "--paginate-by-heading":
+			opts.paginate = true
+		case "--paginate-depth":
+			opts.paginateDepth, err = levelValue(args, &i)
+		case "--raw":
+			opts.raw = true
+		case "--no-color":
+			opts.noColor = true
+		case "--no-emoji":
+			opts.noEmoji = true
+		case "--pager-always":
+			opts.pagerAlways = true
+		case "--no-pager":
+			opts.noPager = true
+		case "--close-on-exit":
+			opts.closeOnExit = true
+		case "--no-open":
+			opts.noOpen = true
+		case "-q", "--quiet":
+			opts.quiet = true
+		case "--open-with":
+			opts.openWith, err = flagValue(args, &i)
+		case "--host":
+			opts.host, err = flagValue(args, &i)
+		case "--port":
+			opts.port, err = portValue(args, &i)
+		case "--unix":
+			opts.unixSocket, err = flagValue(args, &i)
+		case "-w", "--watch":
+			opts.watch = true
+		case "--serve":
+			opts.serveDir, err = flagValue(args, &i)
+		case "--recent":
+			opts.recent = true
+		case "--latest":
+			opts.latest, err = flagValue(args, &i)
+		case "--recursive":
+			opts.recursive = true
+		case "--base":
+			opts.base, err = flagValue(args, &i)
+		case "--reload-debounce":
+			opts.reloadDebounce, err = durationValue(args, &i)
+		case "--reload-transport":
+			opts.reloadTransport, err = flagValue(args, &i)
+			if err == nil && opts.reloadTransport != "sse" && opts.reloadTransport != "ws" {
+				err = fmt.Errorf("invalid --reload-transport %q (expected sse or ws)", opts.reloadTransport)
+			}
+		case "--follow-links":
+			opts.followLinks = true
+		case "--reader-width":
+			opts.readerWidth, err = positiveValue(args, &i)
+		case "--columns":
+			var value string
+			if value, err = flagValue(args, &i); err == nil {
+				opts.columns, err = strconv.Atoi(value)
+				if err != nil || opts.columns < 1 || opts.columns > 3 {
+					err = fmt.Errorf("invalid --columns %q (expected 1 to 3)", value)
+				}
+			}
+		case "--reader-font":
+			if opts.readerFont, err = flagValue(args, &i); err == nil && readerFonts[opts.readerFont] == "" {
+				err = fmt.Errorf("invalid --reader-font %q (expected serif, sans or mono)", opts.readerFont)
+			}
+		case "--toc":
+			opts.toc = true
+		case "--toc-depth":
+			opts.tocDepth, err = levelValue(args, &i)
+		case "--title":
+			opts.title, err = flagValue(args, &i)
+		case "--math":
+			opts.math = true
+		case "--mermaid":
+			opts.mermaid = true
+		case "--collapse-code":
+			opts.collapseCode = true
+		case "--line-numbers":
+			opts.lineNums = true
+		case "--no-highlight":
+			opts.noHighlight = true
+		case "--twemoji":
+			opts.twemoji = true
+		case "--tab-size":
+			opts.tabSize, err = positiveValue(args, &i)
+		case "--code-style":
+			opts.codeStyle, err = flagValue(args, &i)
+		case "-o", "--output":
+			opts.output, err = flagValue(args, &i)
+		case "--minify":
+			opts.minify = true
+		case "--copy":
+			opts.copy = true
+		case "--pdf":
+			opts.pdf, err = flagValue(args, &i)
+		case "--theme":
+			opts.theme, err = flagValue(args, &i)
+		case "--css":
+			opts.cssFile, err = flagValue(args, &i)
+		case "--css-replace":
+			opts.cssFile, err = flagValue(args, &i)
+			opts.cssReplace = true
+		case "--template":
+			opts.templateFile, err = flagValue(args, &i)
+		default:
+			if inline[i+1] {
+				arg += "=" + args[i+1]
+				i++
+			}
+			remaining = append(remaining, arg)
+		}
+		if err == nil && i == start && inline[i+1] {
+			err = fmt.Errorf("flag %s does not take a value", arg)
+		}
+		if err != nil {
+			return opts, nil, err
+		}
+	}
+
+	if err := validateTheme(opts); err != nil {
+		return opts, nil, err
+	}
+	if opts.grep != "" {
+		if _, err := grepPattern(opts); err != nil {
+			return opts, nil, err
+		}
+	}
+	return opts, remaining, validateCodeStyle(opts.codeStyle)
+}
+
+// splitFlagValues turns --name=value into --name value. inline marks the
+// values split off this way, so flags without a value can refuse them.
+func splitFlagValues(args []string) (split []string, inline map[int]bool) {
+	inline = make(map[int]bool)
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(name, "--") {
+			split = append(split, name, value)
+			inline[len(split)-1] = true
+			continue
+		}
+		split = append(split, arg)
+	}
+	return split, inline
+}
+
+func widthValue(args []string, i *int) (int, error) {
+	value, err := flagValue(args, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid width %q (expected a non-negative integer)", value)
+	}
+	return n, nil
+}
+
+func portValue(args []string, i *int) (int, error) {
+	value, err := flagValue(args, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q (expected 0-65535)", value)
+	}
+	return n, nil
+}
+
+func positiveValue(args []string, i *int) (int, error) {
+	value, err := flagValue(args, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q (expected a positive number)", args[*i-1], value)
+	}
+	return n, nil
+}
+
+// levelValue parses the value of a flag as a heading level.
+func levelValue(args []string, i *int) (int, error) {
+	value, err := flagValue(args, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 6 {
+		return 0, fmt.Errorf("invalid %s %q (expected 1 to 6)", args[*i-1], value)
+	}
+	return n, nil
+}
+
+// durationValue parses the value of a flag as a positive Go duration.
+func durationValue(args []string, i *int) (time.Duration, error) {
+	value, err := flagValue(args, i)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q (expected a duration like 10s)", args[*i-1], value)
+	}
+	return d, nil
+}
+
+// flagValue consumes the argument following the flag at args[*i].
+func flagValue(args []string, i *int) (string, error) {
+	if *i+1 >= len(args) {
+		return "", fmt.Errorf("%s requires a value", args[*i])
+	}
+	*i++
+	return args[*i], nil
+}
+
+// markdownExts are the file extensions recognised as markdown.
+var markdownExts = []string{".md", ".markdown", ".mdown", ".mkd"}
+
+func getInput(args []string, opts options) ([]document, error) {
+	docs, err := readInput(args, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.base != "" {
+		if info, err := os.Stat(opts.base); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("--base: %s is not a directory", opts.base)
+		}
+	}
+
+	for i, doc := range docs {
+		if docs[i], err = prepareDocument(doc, opts); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// prepareDocument turns the raw bytes of a document into the markdown to
+// render: decompressed, decoded, converted from HTML, cut down by --since
+// and --section, and with includes expanded. --watch runs it again on
+// every change.
+func prepareDocument(doc document, opts options) (document, error) {
+	var err error
+	if doc.md, err = gunzip(doc); err != nil {
+		return doc, err
+	}
+	if doc.md, err = decodeInput(doc.md, opts); err != nil {
+		return doc, err
+	}
+	if opts.fromHTML {
+		if doc.md, err = htmlToMarkdown(doc.md); err != nil {
+			return doc, err
+		}
+	} else if !opts.force {
+		if err := checkInput(doc); err != nil {
+			return doc, err
+		}
+	}
+	if opts.since != "" {
+		if doc.md, err = changedSince(doc, opts.since); err != nil {
+			return doc, err
+		}
+	}
+	if doc.path == "" {
+		doc.base = opts.base
+	}
+	if dir := doc.dir(); dir != "" {
+		if doc.md, err = expandIncludes(doc.md, dir); err != nil {
+			return doc, err
+		}
+	}
+	if opts.section != "" {
+		if doc.md, err = extractSection(doc, opts.section, opts); err != nil {
+			return doc, err
+		}
+	}
+	return doc, nil
+}
+
+// gunzip decompresses gzip input, recognised by its magic bytes or a .gz
+// extension. Other input is returned unchanged.
+func gunzip(doc document) ([]byte, error) {
+	if !bytes.HasPrefix(doc.md, []byte{0x1f, 0x8b}) && !strings.HasSuffix(doc.path, ".gz") {
+		return doc.md, nil
+	}
+	name := doc.path
+	if name == "" {
+		name = "input"
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(doc.md))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid gzip data: %w", name, err)
+	}
+	md, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid gzip data: %w", name, err)
+	}
+	return md, nil
+}
+
+// checkInput rejects binary input and HTML pages, and warns about files
+// that do not look like markdown.
+func checkInput(doc document) error {
+	name := doc.path
+	if name == "" {
+		name = "input"
+	}
+	if bytes.IndexByte(doc.md[:min(len(doc.md), 1024)], 0) >= 0 {
+		return fmt.Errorf("%s: looks like a binary file (use --force to read it anyway)", name)
+	}
+	if looksLikeHTML(doc.md) {
+		return fmt.Errorf("%s: looks like an HTML page (use --from-html to convert it, or --force to read it as markdown)", name)
+	}
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(doc.path, ".gz")))
+	if doc.path != "" && !slices.Contains(markdownExts, ext) {
+		fmt.Fprintf(os.Stderr, "marko: warning: %s does not have a markdown extension\n", name)
+	}
+	return nil
+}
+
+func readInput(args []string, opts options) ([]document, error) {
+	if len(args) == 0 {
+		if stdinIsPiped() {
+			return readStdin(opts.timeout)
+		}
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	switch args[0] {
+	case "--help", "-h":
+		fmt.Println(usage)
+		os.Exit(0)
+	case "--version", "-v":
+		printVersion(slices.Contains(args, "--short"))
+		os.Exit(0)
+	case "-":
+		return readStdin(opts.timeout)
+	}
+
+	args, err := expandGlobs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]document, 0, len(args))
+	for _, arg := range args {
+		var doc document
+		if isURL(arg) {
+			doc, err = fetchURL(arg, cmp.Or(opts.timeout, fetchTimeout))
+		} else {
+			doc, err = readFile(arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
+// expandGlobs expands patterns like docs/*.md that the shell left alone,
+// e.g. because they were quoted or on Windows. Arguments naming an existing
+// file are kept as they are, so already expanded names are not expanded
+// again.
+func expandGlobs(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		if isURL(arg) || !strings.ContainsAny(arg, "*?[") {
+			expanded = append(expanded, arg)
+			continue
+		}
+		if _, err := os.Stat(arg); err == nil {
+			expanded = append(expanded, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// printVersion prints the version with the Go toolchain, platform and VCS
+// revision embedded at build time, or just the version if short is set.
+func printVersion(short bool) {
+	if short {
+		fmt.Println(version)
+		return
+	}
+	fmt.Printf("marko %s\n", version)
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	var revision, modified, built string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		case "vcs.time":
+			built = s.Value
+		}
+	}
+	if revision != "" {
+		if modified == "true" {
+			revision += " (modified)"
+		}
+		fmt.Printf("  commit:   %s\n", revision)
+	}
+	if built != "" {
+		fmt.Printf("  date:     %s\n", built)
+	}
+	fmt.Printf("  go:       %s\n", info.GoVersion)
+	fmt.Printf("  platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+}
+
+// readStdin reads all of stdin, giving up after timeout unless it is 0.
+func readStdin(timeout time.Duration) ([]document, error) {
+	if timeout == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return []document{{md: data}}, nil
+	}
+
+	// Deadlines are not supported on every kind of stdin, so the read is
+	// abandoned instead; marko exits soon after anyway.
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := io.ReadAll(os.Stdin)
+		done <- result{data, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return []document{{md: r.data}}, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("stdin: no end of input after %s", timeout)
+	}
+}
+
+func readFile(path string) (document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return document{}, err
+	}
+
+	if len(data) == 0 {
+		return document{}, fmt.Errorf("%s: file is empty", path)
+	}
+
+	return document{path: path, md: data}, nil
+}
+
+func fetchURL(url string, timeout time.Duration) (document, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return document{}, err
+	}
+	req.Header.Set("User-Agent", "marko/"+version)
+	req.Header.Set("Accept", "text/markdown, text/plain;q=0.9, */*;q=0.5")
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if isTimeout(err) {
+		return document{}, fmt.Errorf("%s: no response after %s", url, timeout)
+	}
+	if err != nil {
+		return document{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return document{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if isTimeout(err) {
+		return document{}, fmt.Errorf("%s: download not finished after %s", url, timeout)
+	}
+	if err != nil {
+		return document{}, fmt.Errorf("%s: %w", url, err)
+	}
+	if len(data) == 0 {
+		return document{}, fmt.Errorf("%s: document is empty", url)
+	}
+
+	return document{md: data}, nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// --- Terminal rendering ---
+
+func render(md []byte, width int, opts options) (string, error) {
+	md = expandCodeTabs(md, opts.tabSize)
+	if opts.showURLs {
+		md = showURLs(md)
+	}
+
+	style := glamour.WithAutoStyle()
+	switch {
+	case opts.noColor:
+		style = glamour.WithStandardStyle(glamourstyles.NoTTYStyle)
+	case opts.style != "" && opts.style != "auto":
+		style = glamour.WithStylePath(opts.style)
+	}
+
+	termOpts := []glamour.TermRendererOption{style, glamour.WithWordWrap(width)}
+	if !opts.noEmoji {
+		termOpts = append(termOpts, glamour.WithEmoji())
+	}
+
+	r, err := glamour.NewTermRenderer(termOpts...)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := r.Render(string(md))
+	if err != nil {
+		return "", err
+	}
+	if opts.osc8 && osc8Supported() {
+		rendered = addHyperlinks(rendered, collectOutline(md, opts).Links)
+	}
+	if opts.grep == "" {
+		return rendered, nil
+	}
+
+	re, err := grepPattern(opts)
+	if err != nil {
+		return "", err
+	}
+	return highlightMatches(rendered, re), nil
+}
+
+// expandCodeTabs replaces tabs in fenced code blocks with spaces up to the
+// next multiple of size, since terminals use 8 column tab stops.
+func expandCodeTabs(md []byte, size int) []byte {
+	var out bytes.Buffer
+	var fence []byte
+	for line := range bytes.Lines(md) {
+		trimmed := bytes.TrimLeft(line, " ")
+		switch {
+		case fence != nil && bytes.HasPrefix(trimmed, fence):
+			fence = nil
+		case fence != nil:
+			col := 0
+			for _, r := range string(line) {
+				if r == '\t' {
+					n := size - col%size
+					out.WriteString(strings.Repeat(" ", n))
+					col += n
+					continue
+				}
+				out.WriteRune(r)
+				col++
+			}
+			continue
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			fence = trimmed[:3]
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+func output(rendered string, opts options) {
+	if opts.noPager || !stdoutIsTTY() {
+		fmt.Print(rendered)
+		return
+	}
+
+	height := terminalHeight()
+	lines := strings.Count(rendered, "\n")
+
+	if lines <= height && !opts.pagerAlways {
+		fmt.Print(rendered)
+		return
+	}
+
+	pagerCmd := opts.pager
+	if opts.pagerAlways && pagerCmd == defaultPager {
+		// With -F, less would quit straight away on a short document.
+		pagerCmd = strings.Replace(pagerCmd, " -F", "", 1)
+	}
+	if err := pager(rendered, pagerCmd); err != nil {
+		fmt.Print(rendered)
+	}
+}
+
+func pager(content, pagerCmd string) error {
+	parts, err := findPager(pagerCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marko: %s, printing directly\n", err)
+		return err
+	}
+
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// findPager returns the pager command to run. When the configured pager is
+// not installed it falls back to less, then more.
+func findPager(pagerCmd string) ([]string, error) {
+	for _, candidate := range []string{pagerCmd, defaultPager, "more"} {
+		parts := strings.Fields(candidate)
+		if len(parts) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(parts[0]); err != nil {
+			continue
+		}
+		if candidate != pagerCmd {
+			fmt.Fprintf(os.Stderr, "marko: pager %q not found, using %q\n", pagerCmd, candidate)
+		}
+		return parts, nil
+	}
+	return nil, fmt.Errorf("no pager found (tried %q, %q and \"more\")", pagerCmd, defaultPager)
+}
+
+// --- Visual reader ---
+
+// readerDoc is one document served by the reader. It is re-rendered in place
+// when --watch picks up a change to its file.
+type readerDoc struct {
+	mu     sync.RWMutex
+	path   string
+	dir    string
+	route  string
+	events *hub
+	title  string
+	toc    string
+	body   string
+	md     []byte            // source last rendered, offered at /source
+	sum    [sha256.Size]byte // hash of the source last rendered
+}
+
+func newReaderDoc(doc document, route string, opts options) *readerDoc {
+	d := &readerDoc{path: doc.path, dir: doc.dir(), route: route}
+	d.update(doc.md, opts)
+	return d
+}
+
+func (d *readerDoc) update(md []byte, opts options) {
+	// Editors often touch or rewrite a file without changing it; skip the
+	// render and the reload when the source is the same as last time.
+	sum := sha256.Sum256(md)
+	d.mu.RLock()
+	unchanged := sum == d.sum
+	d.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	src := md
+	title := extractTitle(md)
+	if opts.title != "" {
+		title = opts.title
+	}
+	_, md = stripFrontmatter(md)
+	body := renderHTML(md, opts)
+	var toc string
+	if opts.toc || opts.pageTemplate != nil {
+		toc = renderTOC(collectHeadings(md, opts))
+	}
+
+	d.mu.Lock()
+	d.title, d.toc, d.body, d.md, d.sum = title, toc, body, src, sum
+	d.mu.Unlock()
+
+	if d.events != nil {
+		msg, _ := json.Marshal(map[string]string{"title": title, "toc": toc, "body": body})
+		d.events.broadcast(string(msg))
+	}
+}
 
-Options:
-  -t, --term    Render in terminal instead of visual reader
-  --help        Show this help
-  --version     Show version
+func (d *readerDoc) page() page {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return page{title: d.title, toc: d.toc, body: d.body}
+}
 
-Environment:
-  GLAMOUR_STYLE   Set terminal rendering style (dark, light, notty, dracula, ascii)
-  PAGER           Set pager command (default: less -r)`
+// label is the name shown for the document in the sidebar.
+func (d *readerDoc) label() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.title == defaultTitle && d.path != "" {
+		return filepath.Base(d.path)
+	}
+	return d.title
+}
 
-func main() {
-	if err := run(); err != nil {
-		fmt.Fprintf(os.Stderr, "marko: %s\n", err)
-		os.Exit(1)
+// serveSource sends the markdown as a download named after the file, or
+// document.md for stdin and URLs.
+func (d *readerDoc) serveSource(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	md := d.md
+	d.mu.RUnlock()
+
+	name := "document.md"
+	if d.path != "" {
+		name = strings.TrimSuffix(filepath.Base(d.path), ".gz")
 	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Write(md)
 }
 
-func run() error {
-	termMode, args := parseFlags(os.Args[1:])
+func (d *readerDoc) eventsRoute() string {
+	return strings.TrimSuffix(d.route, "/") + "/events"
+}
 
-	md, err := getInput(args)
+func openReader(docs []document, opts options) error {
+	ln, url, err := listen(opts)
 	if err != nil {
 		return err
 	}
 
-	if termMode {
-		width := terminalWidth()
-		rendered, err := render(md, width)
-		if err != nil {
-			return fmt.Errorf("render failed: %w", err)
+	mux := http.NewServeMux()
+	srv := &http.Server{Handler: mux}
+
+	rdocs := make([]*readerDoc, len(docs))
+	for i, doc := range docs {
+		// Each document gets its own directory-like route so that relative
+		// asset URLs resolve underneath it.
+		route := "/"
+		if len(docs) > 1 {
+			route = fmt.Sprintf("/%d/", i)
 		}
-		output(rendered)
-		return nil
+		rdocs[i] = newReaderDoc(doc, route, opts)
+	}
+	if len(rdocs) > 1 {
+		mux.Handle("/{$}", http.RedirectHandler(rdocs[0].route, http.StatusFound))
 	}
 
-	return openReader(md)
-}
+	for i, d := range rdocs {
+		mux.HandleFunc(d.route+"{$}", func(w http.ResponseWriter, r *http.Request) {
+			p := d.page()
+			p.sidebar = sidebar(rdocs, i)
+			if d.events != nil {
+				p.events = d.eventsRoute()
+			}
+			p.heartbeat = opts.closeOnExit
+			p.source = d.route + "source"
 
-func parseFlags(args []string) (termMode bool, remaining []string) {
-	for _, arg := range args {
-		switch arg {
-		case "-t", "--term":
-			termMode = true
-		default:
-			remaining = append(remaining, arg)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, readerPage(p, opts))
+		})
+		mux.HandleFunc(d.route+"source", d.serveSource)
+
+		if d.dir != "" {
+			var linked func(http.ResponseWriter, document)
+			if opts.followLinks {
+				linked = func(w http.ResponseWriter, doc document) {
+					writeLinkedPage(w, doc, d.route, sidebar(rdocs, i), opts)
+				}
+			}
+			assets, err := serveAssets(mux, d.route, d.dir, linked)
+			if err != nil {
+				return err
+			}
+			defer assets.Close()
 		}
-	}
-	return
-}
 
-func getInput(args []string) ([]byte, error) {
-	if len(args) == 0 {
-		if stdinIsPiped() {
-			return io.ReadAll(os.Stdin)
+		if !opts.watch {
+			continue
+		}
+		if d.path == "" {
+			fmt.Fprintln(os.Stderr, "marko: --watch ignored, input is not a file")
+			continue
 		}
-		fmt.Println(usage)
-		os.Exit(0)
-	}
 
-	switch args[0] {
-	case "--help", "-h":
-		fmt.Println(usage)
-		os.Exit(0)
-	case "--version", "-v":
-		fmt.Printf("marko %s\n", version)
-		os.Exit(0)
-	case "-":
-		return io.ReadAll(os.Stdin)
-	}
+		d.events = newHub()
+		if opts.reloadTransport == "ws" {
+			mux.Handle(d.eventsRoute(), d.events.serveWebSocket())
+		} else {
+			mux.Handle(d.eventsRoute(), d.events)
+		}
+		srv.RegisterOnShutdown(d.events.close)
 
-	if len(args) > 1 {
-		return nil, fmt.Errorf("too many arguments (expected 1 file)")
+		w, err := watchFile(d.path, opts.reloadDebounce, func(md []byte) {
+			doc, err := prepareDocument(document{path: d.path, md: md}, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "marko: %s\n", err)
+				return
+			}
+			d.update(doc.md, opts)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", d.path, err)
+		}
+		defer w.Close()
 	}
 
-	path := args[0]
-	data, err := os.ReadFile(path)
+	return serveReader(srv, mux, ln, url, opts)
+}
+
+// listen opens the reader's socket and returns it with the URL to browse.
+func listen(opts options) (net.Listener, string, error) {
+	if opts.unixSocket != "" {
+		return listenUnix(opts.unixSocket)
+	}
+	if err := checkHost(opts.host); err != nil {
+		return nil, "", err
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(opts.host, strconv.Itoa(opts.port)))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, "", fmt.Errorf("failed to start server: port %d is already in use", opts.port)
+	}
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("failed to start server: %w", err)
 	}
 
-	if len(data) == 0 {
-		return nil, fmt.Errorf("%s: file is empty", path)
+	url := "http://" + ln.Addr().String()
+	if addr := ln.Addr().(*net.TCPAddr); addr.IP.IsUnspecified() {
+		url = "http://" + net.JoinHostPort("localhost", strconv.Itoa(addr.Port))
 	}
-
-	return data, nil
+	return ln, url, nil
 }
 
-// --- Terminal rendering ---
-
-func render(md []byte, width int) (string, error) {
-	r, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
-		glamour.WithWordWrap(width),
-		glamour.WithEmoji(),
-	)
+// listenUnix listens on a Unix socket at path, replacing a socket file left
+// behind by a reader that did not shut down cleanly. The listener removes
+// the file again when it is closed.
+func listenUnix(path string) (net.Listener, string, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, "", fmt.Errorf("failed to start server: %s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("failed to start server: %s is already in use", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
 	if err != nil {
-		return "", err
+		return nil, "", fmt.Errorf("failed to start server: %w", err)
 	}
-	return r.Render(string(md))
+	return ln, "http://localhost", nil
 }
 
-func output(rendered string) {
-	if !stdoutIsTTY() {
-		fmt.Print(rendered)
-		return
+// serveReader runs the reader server until Ctrl+C, or until all tabs are
+// closed with --close-on-exit.
+func serveReader(srv *http.Server, mux *http.ServeMux, ln net.Listener, url string, opts options) error {
+	var idle <-chan struct{}
+	if opts.closeOnExit {
+		tabs := newHeartbeat()
+		mux.Handle("/ping", tabs)
+		idle = tabs.idle()
 	}
 
-	height := terminalHeight()
-	lines := strings.Count(rendered, "\n")
+	go srv.Serve(ln)
 
-	if lines <= height {
-		fmt.Print(rendered)
-		return
+	// With --quiet, scripts that open the page themselves still get the
+	// address, on a line of its own.
+	browser := !opts.noOpen && opts.unixSocket == ""
+	switch {
+	case opts.quiet && !browser:
+		fmt.Println(cmp.Or(opts.unixSocket, url))
+	case opts.quiet:
+	case opts.unixSocket != "":
+		fmt.Printf("Reader listening on %s — Press Ctrl+C to close\n", opts.unixSocket)
+		fmt.Printf("Connect with: curl --unix-socket %s %s/\n", opts.unixSocket, url)
+	default:
+		fmt.Printf("Reader opened at %s — Press Ctrl+C to close\n", url)
 	}
-
-	if err := pager(rendered); err != nil {
-		fmt.Print(rendered)
+	if browser {
+		openBrowser(url, opts.openWith)
 	}
-}
 
-func pager(content string) error {
-	pagerCmd := os.Getenv("PAGER")
-	if pagerCmd == "" {
-		pagerCmd = "less -r"
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	select {
+	case <-sig:
+		if !opts.quiet {
+			fmt.Println()
+		}
+	case <-idle:
 	}
 
-	parts := strings.Fields(pagerCmd)
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Stdin = strings.NewReader(content)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
+	if !opts.quiet {
+		fmt.Println("Closing reader...")
+	}
+	return srv.Shutdown(context.Background())
 }
 
-// --- Visual reader ---
+// writeLinkedPage renders a markdown file reached through a link.
+func writeLinkedPage(w http.ResponseWriter, doc document, route, sidebar string, opts options) {
+	doc, err := prepareDocument(doc, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p := newReaderDoc(doc, route, opts).page()
+	p.sidebar = sidebar
+	p.heartbeat = opts.closeOnExit
+	p.back = true
 
-func openReader(md []byte) error {
-	body := renderHTML(md)
-	title := extractTitle(md)
-	page := readerPage(title, body)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, readerPage(p, opts))
+}
 
-	ln, err := net.Listen("tcp", "127.0.0.1:0")
+// checkHost validates the --host address and warns when it makes the reader
+// reachable from other machines.
+func checkHost(host string) error {
+	ips, err := net.LookupIP(host)
 	if err != nil {
-		return fmt.Errorf("failed to start server: %w", err)
+		return fmt.Errorf("invalid host %q: %w", host, err)
 	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			fmt.Fprintf(os.Stderr, "marko: warning: listening on %s exposes the document to the network\n", host)
+			break
+		}
+	}
+	return nil
+}
 
-	url := "http://" + ln.Addr().String()
-	srv := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			fmt.Fprint(w, page)
-		}),
+// isHiddenPath reports whether a slash-separated path goes through a file
+// or folder starting with a dot, like .git/config or .env. Those are never
+// served, as they are skipped by the --serve index.
+func isHiddenPath(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
 	}
+	return false
+}
 
-	go srv.Serve(ln)
+// serveAssets serves the files next to a document, such as images, under
+// its route. Requests cannot escape dir, even through symlinks. If linked is
+// set, markdown files are passed to it to be rendered instead of served raw.
+func serveAssets(mux *http.ServeMux, route, dir string, linked func(http.ResponseWriter, document)) (io.Closer, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve %s: %w", dir, err)
+	}
+	files := http.FileServerFS(root.FS())
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if isHiddenPath(name) {
+			http.NotFound(w, r)
+			return
+		}
+		ext := strings.ToLower(path.Ext(strings.TrimSuffix(name, ".gz")))
+		if linked == nil || !slices.Contains(markdownExts, ext) {
+			files.ServeHTTP(w, r)
+			return
+		}
+		md, err := fs.ReadFile(root.FS(), name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		linked(w, document{path: filepath.Join(dir, filepath.FromSlash(name)), md: md})
+	})
+	prefix := strings.TrimSuffix(route, "/")
+	mux.Handle(route, http.StripPrefix(prefix, handler))
+	return root, nil
+}
 
-	fmt.Printf("Reader opened at %s — Press Ctrl+C to close\n", url)
-	openBrowser(url)
+func printStyleList() {
+	fmt.Println("Terminal styles:")
+	for _, name := range slices.Sorted(maps.Keys(glamourstyles.DefaultStyles)) {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Println("\nCode styles:")
+	for _, name := range styles.Names() {
+		fmt.Printf("  %s\n", name)
+	}
+}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+// validateStyle accepts a built-in glamour style, "auto", or the path of a
+// JSON style file.
+func validateStyle(name string) error {
+	if name == "auto" || glamourstyles.DefaultStyles[name] != nil {
+		return nil
+	}
+	if _, err := os.Stat(name); err == nil {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(glamourstyles.DefaultStyles))
+	return fmt.Errorf("unknown style %q (valid: %s, or a JSON style file)", name, strings.Join(names, ", "))
+}
 
-	fmt.Println("\nClosing reader...")
-	return srv.Shutdown(context.Background())
+func validateCodeStyle(name string) error {
+	if slices.Contains(styles.Names(), name) {
+		return nil
+	}
+	return fmt.Errorf("unknown code style %q (valid: %s)", name, strings.Join(styles.Names(), ", "))
 }
 
-func renderHTML(md []byte) string {
-	mdParser := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,
+func newMarkdown(opts options) goldmark.Markdown {
+	extensions := []goldmark.Extender{
+		extension.GFM,
+		extension.Footnote,
+		extension.DefinitionList,
+		admonitionExtension{},
+		anchorExtension{},
+		taskExtension{},
+		wikilinkExtension{},
+		subSupExtension{},
+	}
+	// Without highlighting, goldmark writes plain <pre><code class="language-x">.
+	if !opts.noHighlight {
+		extensions = append(extensions,
+			highlightLinesExtension{},
 			highlighting.NewHighlighting(
-				highlighting.WithStyle("dracula"),
+				highlighting.WithStyle(opts.codeStyle),
+				highlighting.WithFormatOptions(
+					chromahtml.WithLineNumbers(opts.lineNums),
+					chromahtml.WithClasses(!opts.inlineStyles),
+				),
+				highlighting.WithCodeBlockOptions(highlightLinesOptions),
 			),
-		),
+		)
+	}
+	if !opts.noEmoji {
+		method := emoji.Unicode
+		if opts.twemoji {
+			method = emoji.Twemoji
+		}
+		extensions = append(extensions, emoji.New(emoji.WithRenderingMethod(method)))
+	}
+	if opts.math {
+		extensions = append(extensions, mathExtension{})
+	}
+	if opts.mermaid {
+		extensions = append(extensions, mermaidExtension{})
+	}
+	if opts.collapseCode {
+		extensions = append(extensions, collapseExtension{})
+	}
+
+	// Raw HTML is passed through unless --safe is set, in which case
+	// goldmark drops it and refuses javascript: style link targets.
+	var rendererOpts []renderer.Option
+	if !opts.safe {
+		rendererOpts = append(rendererOpts, mdhtml.WithUnsafe())
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(extensions...),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 		),
-		goldmark.WithRendererOptions(
-			html.WithUnsafe(),
-		),
+		goldmark.WithRendererOptions(rendererOpts...),
 	)
+}
 
+func renderHTML(md []byte, opts options) string {
 	var buf bytes.Buffer
-	mdParser.Convert(md, &buf)
+	newMarkdown(opts).Convert(md, &buf)
 	return buf.String()
 }
 
+func parseMarkdown(md []byte, opts options) ast.Node {
+	return newMarkdown(opts).Parser().Parse(text.NewReader(md))
+}
+
 func extractTitle(md []byte) string {
-	for _, line := range strings.Split(string(md), "\n") {
-		if strings.HasPrefix(line, "# ") {
-			return strings.TrimPrefix(line, "# ")
+	if title := findTitle(md); title != "" {
+		return title
+	}
+	return defaultTitle
+}
+
+// findTitle returns the frontmatter title or else the first level-one
+// heading, or "" when the document has neither.
+func findTitle(md []byte) string {
+	fm, body := stripFrontmatter(md)
+	if title := fm.title(); title != "" {
+		return title
+	}
+
+	// Parsing rather than scanning lines finds setext (Title / =====) and
+	// indented headings, and skips "# comments" in code blocks.
+	doc := goldmark.New().Parser().Parse(text.NewReader(body))
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok && h.Level == 1 {
+			return plainText(h, body)
 		}
 	}
-	return "marko reader"
+	return ""
 }
 
-func openBrowser(url string) {
+// openBrowser opens url with the browser command given by --open-with or
+// MARKO_BROWSER, falling back to the system default.
+func openBrowser(url, with string) {
+	if parts := strings.Fields(with); len(parts) > 0 {
+		if path, err := exec.LookPath(parts[0]); err == nil {
+			exec.Command(path, append(parts[1:], url)...).Start()
+			return
+		}
+		fmt.Fprintf(os.Stderr, "marko: warning: browser %s not found, using the default\n", parts[0])
+	}
+
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "darwin":
@@ -244,46 +1765,110 @@ func openBrowser(url string) {
 	}
 }
 
-func readerPage(title, content string) string {
+// page holds the per-request pieces of the reader page.
+type page struct {
+	title     string
+	sidebar   string
+	toc       string
+	body      string
+	events    string // live reload endpoint, set when --watch is enabled
+	heartbeat bool   // ping /ping so the reader exits once all tabs close
+	light     bool   // force the github-light theme, e.g. for print
+	back      bool   // show a back button, set on pages reached via --follow-links
+	source    string // markdown download URL, empty for exported pages
+}
+
+func sidebar(docs []*readerDoc, current int) string {
+	if len(docs) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(`<nav class="sidebar"><ul>`)
+	for i, d := range docs {
+		class := ""
+		if i == current {
+			class = ` class="active"`
+		}
+		fmt.Fprintf(&b, `<li><a href="%s"%s>%s</a></li>`, d.route, class, html.EscapeString(d.label()))
+	}
+	b.WriteString(`</ul></nav>`)
+	return b.String()
+}
+
+func readerPage(p page, opts options) string {
+	if opts.pageTemplate != nil {
+		return templatePage(p, opts)
+	}
+
+	bodyClass := ""
+	if p.sidebar != "" {
+		bodyClass = ` class="has-sidebar"`
+	}
+
 	return `<!DOCTYPE html>
 <html lang="en">
 <head>
 <meta charset="utf-8">
 <meta name="viewport" content="width=device-width, initial-scale=1">
-<title>` + title + `</title>
+<title>` + html.EscapeString(p.title) + `</title>
 <style>
-:root {
-  --bg: #ffffff;
-  --fg: #24292e;
-  --secondary: #586069;
-  --border: #e1e4e8;
-  --code-bg: #f6f8fa;
-  --link: #0366d6;
-  --quote-border: #dfe2e5;
-  --table-border: #dfe2e5;
-}
-@media (prefers-color-scheme: dark) {
-  :root {
-    --bg: #0d1117;
-    --fg: #c9d1d9;
-    --secondary: #8b949e;
-    --border: #30363d;
-    --code-bg: #161b22;
-    --link: #58a6ff;
-    --quote-border: #3b434b;
-    --table-border: #30363d;
-  }
+` + readerStyle(p, opts) + `
+</style>` + themeInit(p, opts) + `
+</head>
+<body` + bodyClass + `>
+` + progressBar(p) + themeToggle(p, opts) + p.sidebar + tocNav(p, opts) + `<article>` + backButton(p) + p.body + sourceLink(p) + `</article>` + readerScripts(p, opts) + `
+</body>
+</html>`
+}
+
+// progressBar shows how far the page has been scrolled.
+func progressBar(p page) string {
+	if p.light {
+		return ""
+	}
+	return `<div class="progress" id="progress"></div>
+`
+}
+
+// backButton returns to the previous page after following a link.
+func backButton(p page) string {
+	if !p.back {
+		return ""
+	}
+	return `<button class="back-button" type="button" onclick="history.back()">← Back</button>
+`
+}
+
+// sourceLink offers the markdown the page was rendered from.
+func sourceLink(p page) string {
+	if p.source == "" {
+		return ""
+	}
+	return `<p class="source-link"><a href="` + html.EscapeString(p.source) + `" download>Download markdown</a></p>
+`
 }
+
+func readerStyle(p page, opts options) string {
+	style := defaultStyle(p, opts)
+	if opts.cssReplace {
+		style = ""
+	}
+	return style + codeStyleSheet(p, opts) + "\n" + opts.css
+}
+
+func defaultStyle(p page, opts options) string {
+	return colorScheme(p, opts) + `
 * { margin: 0; padding: 0; box-sizing: border-box; }
 body {
-  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+  font-family: ` + readerFonts[opts.readerFont] + `;
   font-size: 17px;
   line-height: 1.7;
   color: var(--fg);
   background: var(--bg);
   padding: 3rem 1.5rem;
 }
-article { max-width: 720px; margin: 0 auto; }
+article { max-width: ` + strconv.Itoa(opts.readerWidth) + `px; margin: 0 auto; }
 h1, h2, h3, h4, h5, h6 {
   margin-top: 1.5em;
   margin-bottom: 0.5em;
@@ -294,6 +1879,9 @@ h1 { font-size: 2em; border-bottom: 1px solid var(--border); padding-bottom: 0.3
 h2 { font-size: 1.5em; border-bottom: 1px solid var(--border); padding-bottom: 0.3em; }
 h3 { font-size: 1.25em; }
 h1:first-child { margin-top: 0; }
+.anchor { margin-left: 0.3em; color: var(--secondary); opacity: 0; }
+h1:hover .anchor, h2:hover .anchor, h3:hover .anchor,
+h4:hover .anchor, h5:hover .anchor, h6:hover .anchor, .anchor:focus { opacity: 1; text-decoration: none; }
 p { margin-bottom: 1em; }
 a { color: var(--link); text-decoration: none; }
 a:hover { text-decoration: underline; }
@@ -307,32 +1895,607 @@ code {
 pre {
   margin-bottom: 1em;
   padding: 1em;
+  tab-size: ` + strconv.Itoa(opts.tabSize) + `;
   overflow-x: auto;
   border-radius: 8px;
   line-height: 1.5;
   background: var(--code-bg);
 }
 pre code { background: none; padding: 0; }
+pre code .ln {
+  flex-shrink: 0;
+  margin-right: 0.8em !important;
+  border-right: 1px solid var(--border);
+}
+pre code .hl {
+  margin: 0 -1em;
+  padding: 0 1em;
+  box-shadow: inset 3px 0 var(--link);
+}
 blockquote {
   margin-bottom: 1em;
   padding: 0.5em 1em;
   border-left: 4px solid var(--quote-border);
   color: var(--secondary);
 }
+.admonition {
+  margin-bottom: 1em;
+  padding: 0.5em 1em;
+  border-left: 4px solid var(--admonition-color);
+}
+.admonition > :last-child { margin-bottom: 0; }
+.admonition-title { font-weight: 600; color: var(--admonition-color); margin-bottom: 0.25em; }
+.admonition-note { --admonition-color: var(--note); }
+.admonition-tip { --admonition-color: var(--tip); }
+.admonition-important { --admonition-color: var(--important); }
+.admonition-warning { --admonition-color: var(--warning); }
+.admonition-caution { --admonition-color: var(--caution); }
 ul, ol { margin-bottom: 1em; padding-left: 2em; }
 li { margin-bottom: 0.25em; }
+dl { margin-bottom: 1em; }
+dt { margin-top: 0.5em; font-weight: 600; }
+dd { margin-left: 2em; margin-bottom: 0.25em; }
+dd > p { margin-bottom: 0.5em; }
 table { width: 100%; margin-bottom: 1em; border-collapse: collapse; }
 th, td { padding: 0.5em 1em; border: 1px solid var(--table-border); text-align: left; }
 th { font-weight: 600; background: var(--code-bg); }
 img { max-width: 100%; height: auto; }
+img.emoji { height: 1.2em; vertical-align: -0.2em; }
 hr { margin: 1.5em 0; border: none; border-top: 1px solid var(--border); }
+.footnote-ref { font-size: 0.8em; text-decoration: none; }
+.footnotes { margin-top: 2em; color: var(--secondary); font-size: 0.9em; }
+.footnotes ol { padding-left: 1.5em; }
+.footnotes li p { margin-bottom: 0.25em; }
+.footnote-backref { margin-left: 0.25em; text-decoration: none; }
 input[type="checkbox"] { margin-right: 0.5em; }
-</style>
-</head>
-<body>
-<article>` + content + `</article>
-</body>
-</html>`
+.progress {
+  position: fixed;
+  top: 0;
+  left: 0;
+  z-index: 20;
+  height: 3px;
+  background: var(--link);
+}
+.theme-toggle {
+  position: fixed;
+  top: 0.75rem;
+  right: 0.75rem;
+  z-index: 10;
+  width: 2rem;
+  height: 2rem;
+  border: 1px solid var(--border);
+  border-radius: 50%;
+  background: var(--bg);
+  color: var(--fg);
+  font-size: 1rem;
+  cursor: pointer;
+}
+.index-path { margin-left: 0.5em; color: var(--secondary); font-size: 0.85em; }
+.shortcuts {
+  position: fixed;
+  inset: 0;
+  z-index: 30;
+  display: flex;
+  align-items: center;
+  justify-content: center;
+  background: rgba(0, 0, 0, 0.4);
+}
+.shortcuts[hidden] { display: none; }
+.shortcuts-panel {
+  padding: 1.5em 2em;
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  background: var(--bg);
+}
+.shortcuts-panel h2 { margin-top: 0; border-bottom: none; font-size: 1.1em; }
+.shortcuts-panel table { margin: 0; }
+.shortcuts-panel td { padding: 0.3em 1em 0.3em 0; border: none; }
+kbd {
+  padding: 0.1em 0.4em;
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  font-family: "SFMono-Regular", Consolas, "Liberation Mono", Menlo, monospace;
+  font-size: 0.85em;
+}
+.search {
+  position: fixed;
+  top: 1rem;
+  left: 50%;
+  z-index: 25;
+  display: flex;
+  align-items: center;
+  gap: 0.5em;
+  padding: 0.4em 0.6em;
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  background: var(--bg);
+  transform: translateX(-50%);
+}
+.search[hidden] { display: none; }
+.search input {
+  padding: 0.2em 0.4em;
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  background: var(--bg);
+  color: var(--fg);
+  font: inherit;
+}
+.search-count { color: var(--secondary); font-size: 0.85em; }
+mark.search-match { background: #ffe58f; color: #000; }
+mark.search-match.current { background: #ff9632; }
+.diff-stat { font-weight: 600; }
+.diff-added { color: var(--tip); }
+.diff-removed { color: var(--caution); }
+table.diff {
+  font-family: "SFMono-Regular", Consolas, "Liberation Mono", Menlo, monospace;
+  font-size: 0.85em;
+  line-height: 1.5;
+}
+.diff td { padding: 0 0.6em; border: none; white-space: pre-wrap; vertical-align: top; }
+.diff td.diff-num { width: 1%; color: var(--secondary); text-align: right; white-space: nowrap; user-select: none; }
+.diff-add { background: rgba(46, 160, 67, 0.18); }
+.diff-del { background: rgba(248, 81, 73, 0.18); }
+.diff-gap td { padding: 0.2em 0.6em; color: var(--secondary); background: var(--code-bg); text-align: center; }
+.source-link { margin-top: 2em; font-size: 0.85em; }
+.source-link a { color: var(--secondary); }
+.back-button {
+  margin-bottom: 1em;
+  padding: 0;
+  border: none;
+  background: none;
+  color: var(--link);
+  font: inherit;
+  cursor: pointer;
+}
+.code-block { position: relative; }
+.copy-button {
+  position: absolute;
+  top: 0.5em;
+  right: 0.5em;
+  padding: 0.2em 0.6em;
+  border: 1px solid var(--border);
+  border-radius: 4px;
+  background: var(--bg);
+  color: var(--fg);
+  font-size: 0.8em;
+  cursor: pointer;
+  opacity: 0;
+}
+.code-block:hover .copy-button, .copy-button:focus { opacity: 1; }
+div.math { margin-bottom: 1em; overflow-x: auto; }
+div.mermaid { margin-bottom: 1em; text-align: center; }
+details.collapse-code { margin-bottom: 1em; }
+details.collapse-code > summary { cursor: pointer; }
+.collapse-label { color: var(--secondary); font-size: 0.85em; }
+pre.code-preview { margin: 0.3em 0 0; opacity: 0.7; }
+details.collapse-code[open] pre.code-preview { display: none; }
+.sidebar {
+  position: fixed;
+  top: 0;
+  bottom: 0;
+  left: 0;
+  width: 240px;
+  padding: 3rem 1rem;
+  overflow-y: auto;
+  border-right: 1px solid var(--border);
+  font-size: 0.9em;
+}
+.sidebar ul { list-style: none; padding: 0; }
+.sidebar a { display: block; padding: 0.25em 0.5em; border-radius: 4px; color: var(--secondary); }
+.sidebar a.active { color: var(--fg); background: var(--code-bg); font-weight: 600; }
+body.has-sidebar { padding-left: calc(240px + 1.5rem); }
+.toc {
+  position: fixed;
+  top: 0;
+  right: 0;
+  bottom: 0;
+  width: 260px;
+  padding: 3rem 1rem;
+  overflow-y: auto;
+  border-left: 1px solid var(--border);
+  font-size: 0.85em;
+  line-height: 1.5;
+}
+.toc:empty { display: none; }
+.toc ul { list-style: none; padding-left: 0; margin: 0; }
+.toc ul ul { padding-left: 1em; }
+.toc li { margin: 0.2em 0; }
+.toc a { color: var(--secondary); }
+.toc a:hover, .toc a.active { color: var(--link); }
+.toc a.active { font-weight: 600; }
+.toc .toc-h4, .toc .toc-h5, .toc .toc-h6 { font-size: 0.95em; }
+body:has(.toc:not(:empty)) { padding-right: calc(260px + 1.5rem); }
+@media (max-width: 960px) {
+  .sidebar { position: static; width: auto; padding: 0 0 1.5rem; border-right: none; }
+  body.has-sidebar { padding-left: 1.5rem; }
+  .toc { position: static; width: auto; max-width: ` + strconv.Itoa(opts.readerWidth) + `px; margin: 0 auto 2rem; padding: 0 0 1rem; border-left: none; border-bottom: 1px solid var(--border); }
+  body:has(.toc:not(:empty)) { padding-right: 1.5rem; }
+}` + columnStyle(opts) + printStyle()
+}
+
+// columnStyle flows the article into --columns columns. Columns narrower
+// than 22em are dropped, so a small window still gets one. Code, tables
+// and diagrams span the whole width rather than being cut.
+func columnStyle(opts options) string {
+	if opts.columns <= 1 {
+		return ""
+	}
+	n := strconv.Itoa(opts.columns)
+	return `
+article {
+  max-width: calc(` + strconv.Itoa(opts.readerWidth) + `px * ` + n + ` + 3rem * ` + strconv.Itoa(opts.columns-1) + `);
+  columns: 22em ` + n + `;
+  column-gap: 3rem;
+  column-rule: 1px solid var(--border);
+}
+article h1, article pre, article table, article .mermaid, article .diff { column-span: all; }
+article h2, article h3, article h4 { break-after: avoid; }
+article li, article blockquote, article img { break-inside: avoid; }`
+}
+
+func tocNav(p page, opts options) string {
+	if !opts.toc {
+		return ""
+	}
+	return `<nav class="toc" id="toc">` + p.toc + `</nav>`
+}
+
+func readerScripts(p page, opts options) string {
+	var scripts string
+	if fixedTheme(p, opts) == "" {
+		scripts += `
+<script>
+document.getElementById("theme-toggle").addEventListener("click", function () {
+  var root = document.documentElement;
+  var current = root.getAttribute("data-theme") ||
+    (matchMedia("(prefers-color-scheme: dark)").matches ? "dark" : "light");
+  var next = current === "dark" ? "light" : "dark";
+  root.setAttribute("data-theme", next);
+  localStorage.setItem("marko-theme", next);
+});
+</script>`
+	}
+	if p.events != "" {
+		listen := `new EventSource("` + p.events + `").onmessage = function (e) { apply(e.data); };`
+		if opts.reloadTransport == "ws" {
+			// Unlike EventSource, a WebSocket does not reconnect by itself.
+			listen = `(function connect() {
+  var ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "` + p.events + `");
+  ws.onmessage = function (e) { apply(e.data); };
+  ws.onclose = function () { setTimeout(connect, 1000); };
+})();`
+		}
+		scripts += `
+<script>
+function apply(data) {
+  var update = JSON.parse(data);
+  var y = scrollY;
+  document.title = update.title;
+  var article = document.querySelector("article");
+  var source = article.querySelector(".source-link");
+  article.innerHTML = update.body;
+  if (source) article.appendChild(source);
+  var toc = document.getElementById("toc");
+  if (toc) toc.innerHTML = update.toc;
+  // Stay in place while editing, or at the bottom if the page got shorter.
+  scrollTo(0, Math.min(y, document.documentElement.scrollHeight - innerHeight));
+  document.dispatchEvent(new Event("marko:update"));
+}
+` + listen + `
+</script>`
+	}
+	// Task checkboxes are keyed by their position in the document, so the
+	// saved state survives reloads as long as no task is added above.
+	scripts += `
+<script>
+(function () {
+  var key = "marko-tasks:" + location.pathname;
+  function tasks() { return document.querySelectorAll("article input.task"); }
+  function restore() {
+    var saved = JSON.parse(localStorage.getItem(key) || "null");
+    if (!saved) return;
+    tasks().forEach(function (box, i) {
+      if (i in saved) box.checked = saved[i];
+    });
+  }
+  document.addEventListener("change", function (e) {
+    if (!e.target.matches("article input.task")) return;
+    var state = {};
+    tasks().forEach(function (box, i) { state[i] = box.checked; });
+    localStorage.setItem(key, JSON.stringify(state));
+  });
+  restore();
+  document.addEventListener("marko:update", restore);
+})();
+</script>`
+	if !p.light {
+		scripts += `
+<script>
+(function () {
+  function addCopyButtons() {
+    document.querySelectorAll("article pre:not(.code-preview)").forEach(function (pre) {
+      if (pre.parentNode.classList.contains("code-block")) return;
+      var block = document.createElement("div");
+      block.className = "code-block";
+      pre.parentNode.insertBefore(block, pre);
+      block.appendChild(pre);
+
+      var button = document.createElement("button");
+      button.className = "copy-button";
+      button.type = "button";
+      button.textContent = "Copy";
+      button.addEventListener("click", function () {
+        // Leave out line numbers, which chroma marks as unselectable.
+        var code = pre.cloneNode(true);
+        code.querySelectorAll(".ln").forEach(function (n) { n.remove(); });
+        navigator.clipboard.writeText(code.textContent).then(function () {
+          button.textContent = "Copied!";
+          setTimeout(function () { button.textContent = "Copy"; }, 1500);
+        });
+      });
+      block.appendChild(button);
+    });
+  }
+  addCopyButtons();
+  document.addEventListener("marko:update", addCopyButtons);
+})();
+</script>`
+	}
+	if !p.light {
+		scripts += `
+<script>
+(function () {
+  var bar = document.getElementById("progress");
+  function update() {
+    var max = document.documentElement.scrollHeight - innerHeight;
+    bar.style.width = (max > 0 ? scrollY / max * 100 : 0) + "%";
+  }
+  addEventListener("scroll", update, { passive: true });
+  addEventListener("resize", update);
+  document.addEventListener("marko:update", update);
+  update();
+})();
+</script>`
+	}
+	if opts.toc {
+		// Scroll spy: the table of contents marks the last heading that has
+		// scrolled past the top third of the window.
+		scripts += `
+<script>
+(function () {
+  var toc = document.getElementById("toc");
+  var observer, entries = [];
+  function update() {
+    var current = entries[0];
+    entries.forEach(function (e) {
+      if (e.heading.getBoundingClientRect().top < innerHeight / 3) current = e;
+    });
+    entries.forEach(function (e) { e.link.classList.toggle("active", e === current); });
+    if (current && getComputedStyle(toc).position === "fixed") {
+      current.link.scrollIntoView({ block: "nearest" });
+    }
+  }
+  function observe() {
+    if (observer) observer.disconnect();
+    observer = new IntersectionObserver(update, { rootMargin: "0px 0px -66% 0px" });
+    entries = [];
+    toc.querySelectorAll("a[href^='#']").forEach(function (link) {
+      var heading = document.getElementById(link.getAttribute("href").slice(1));
+      if (!heading) return;
+      entries.push({ link: link, heading: heading });
+      observer.observe(heading);
+    });
+    update();
+  }
+  document.addEventListener("marko:update", observe);
+  observe();
+})();
+</script>`
+	}
+	if !p.light {
+		scripts += `
+<script>
+(function () {
+  var toggle = document.getElementById("theme-toggle");
+  var toc = document.getElementById("toc");
+  var keys = [["?", "Show this help"], ["/", "Search the page"]];
+  if (toggle) keys.push(["t", "Toggle dark mode"]);
+  keys.push(["g", "Jump to top"], ["G", "Jump to bottom"]);
+  if (toc) keys.push(["o", "Open the table of contents"]);
+  keys.push(["c", "Copy the first code block in view"], ["Esc", "Close this help"]);
+
+  var overlay = document.createElement("div");
+  overlay.className = "shortcuts";
+  overlay.hidden = true;
+  overlay.innerHTML = '<div class="shortcuts-panel" role="dialog" aria-label="Keyboard shortcuts"><h2>Keyboard shortcuts</h2><table></table></div>';
+  keys.forEach(function (k) {
+    var row = overlay.querySelector("table").insertRow();
+    row.insertCell().innerHTML = "<kbd>" + k[0] + "</kbd>";
+    row.insertCell().textContent = k[1];
+  });
+  overlay.addEventListener("click", function (e) {
+    if (e.target === overlay) overlay.hidden = true;
+  });
+  document.body.appendChild(overlay);
+
+  function copyInView() {
+    var buttons = document.querySelectorAll("article .copy-button");
+    for (var i = 0; i < buttons.length; i++) {
+      var rect = buttons[i].parentNode.getBoundingClientRect();
+      if (rect.height > 0 && rect.bottom > 0 && rect.top < innerHeight) {
+        buttons[i].click();
+        return;
+      }
+    }
+  }
+
+  document.addEventListener("keydown", function (e) {
+    if (e.ctrlKey || e.metaKey || e.altKey) return;
+    if (e.target.closest("input, textarea, select, [contenteditable]")) return;
+    if (e.key === "Escape") {
+      overlay.hidden = true;
+      return;
+    }
+    switch (e.key) {
+    case "?": overlay.hidden = !overlay.hidden; break;
+    case "t": if (toggle) toggle.click(); break;
+    case "g": scrollTo(0, 0); break;
+    case "G": scrollTo(0, document.documentElement.scrollHeight); break;
+    case "o":
+      if (!toc) return;
+      toc.scrollIntoView({ block: "nearest" });
+      var link = toc.querySelector("a");
+      if (link) link.focus();
+      break;
+    case "c": copyInView(); break;
+    default: return;
+    }
+    e.preventDefault();
+  });
+})();
+</script>`
+	}
+	if !p.light {
+		scripts += `
+<script>
+(function () {
+  var bar = document.createElement("div");
+  bar.className = "search";
+  bar.hidden = true;
+  bar.innerHTML = '<input type="search" placeholder="Search" aria-label="Search the page"><span class="search-count"></span>';
+  document.body.appendChild(bar);
+  var input = bar.querySelector("input");
+  var count = bar.querySelector(".search-count");
+  var marks = [];
+  var current = -1;
+
+  function clear() {
+    marks.forEach(function (mark) {
+      var parent = mark.parentNode;
+      parent.replaceChild(mark.firstChild, mark);
+      parent.normalize();
+    });
+    marks = [];
+    current = -1;
+  }
+
+  // Wrap every case-insensitive match in the article's text in a <mark>.
+  function search(query) {
+    clear();
+    var needle = query.toLowerCase();
+    if (needle) {
+      var walker = document.createTreeWalker(document.querySelector("article"), NodeFilter.SHOW_TEXT);
+      var nodes = [];
+      while (walker.nextNode()) nodes.push(walker.currentNode);
+      nodes.forEach(function (node) {
+        var i;
+        while ((i = node.nodeValue.toLowerCase().indexOf(needle)) >= 0) {
+          var match = node.splitText(i);
+          node = match.splitText(needle.length);
+          var mark = document.createElement("mark");
+          mark.className = "search-match";
+          match.parentNode.replaceChild(mark, match);
+          mark.appendChild(match);
+          marks.push(mark);
+        }
+      });
+    }
+    show(0);
+  }
+
+  function show(i) {
+    if (!marks.length) {
+      count.textContent = input.value ? "No matches" : "";
+      return;
+    }
+    if (current >= 0) marks[current].classList.remove("current");
+    current = (i + marks.length) % marks.length;
+    marks[current].classList.add("current");
+    marks[current].scrollIntoView({ block: "center" });
+    count.textContent = (current + 1) + "/" + marks.length;
+  }
+
+  function close() {
+    clear();
+    count.textContent = "";
+    bar.hidden = true;
+    input.blur();
+  }
+
+  input.addEventListener("input", function () { search(input.value); });
+  input.addEventListener("keydown", function (e) {
+    if (e.key === "Enter") {
+      show(current + (e.shiftKey ? -1 : 1));
+    } else if (e.key === "Escape") {
+      close();
+    } else {
+      return;
+    }
+    e.preventDefault();
+  });
+  document.addEventListener("keydown", function (e) {
+    if (e.key !== "/" || e.ctrlKey || e.metaKey || e.altKey) return;
+    if (e.target.closest("input, textarea, select, [contenteditable]")) return;
+    bar.hidden = false;
+    input.focus();
+    input.select();
+    e.preventDefault();
+  });
+  // A live reload replaces the article, and the highlights with it.
+  document.addEventListener("marko:update", function () {
+    marks = [];
+    if (!bar.hidden) search(input.value);
+  });
+})();
+</script>`
+	}
+	if p.heartbeat {
+		scripts += `
+<script>
+(function () {
+  var tab = Math.random().toString(36).slice(2);
+  function ping(bye) {
+    navigator.sendBeacon("/ping?tab=" + tab + (bye === true ? "&bye=1" : ""));
+  }
+  ping();
+  setInterval(ping, 5000);
+  addEventListener("pagehide", function () { ping(true); });
+  addEventListener("pageshow", function (e) { if (e.persisted) ping(); });
+})();
+</script>`
+	}
+	if opts.math {
+		scripts += `
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/katex@0.16.11/dist/katex.min.css">
+<script src="https://cdn.jsdelivr.net/npm/katex@0.16.11/dist/katex.min.js"></script>
+<script>
+function renderMath() {
+  document.querySelectorAll(".math").forEach(function (el) {
+    katex.render(el.textContent, el, { displayMode: el.classList.contains("display"), throwOnError: false });
+  });
+}
+renderMath();
+document.addEventListener("marko:update", renderMath);
+</script>`
+	}
+	if opts.mermaid {
+		theme := `(document.documentElement.getAttribute("data-theme") ||
+  (matchMedia("(prefers-color-scheme: dark)").matches ? "dark" : "light")) === "dark" ? "dark" : "default"`
+		if name := fixedTheme(p, opts); name != "" {
+			theme = `"default"`
+			if palettes[name].dark {
+				theme = `"dark"`
+			}
+		}
+		scripts += `
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: false, theme: ` + theme + ` });
+mermaid.run();
+document.addEventListener("marko:update", function () { mermaid.run(); });
+</script>`
+	}
+	return scripts
 }
 
 // --- Utilities ---
@@ -349,8 +2512,13 @@ func stdoutIsTTY() bool {
 	return term.IsTerminal(int(os.Stdout.Fd()))
 }
 
+// terminalWidth asks the terminal for its width, then falls back to
+// $COLUMNS, then to 80.
 func terminalWidth() int {
 	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 {
+		w, err = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
 	if err != nil || w <= 0 {
 		return 80
 	}
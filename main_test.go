@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testOptions are the built-in defaults, without the user's config file and
+// environment that loadConfig would read.
+func testOptions() options {
+	return options{codeStyle: defaultCodeStyle, tocDepth: 3, tabSize: 4}
+}
+
+// assertHTML checks that html holds each of want and none of notWant.
+func assertHTML(t *testing.T, html string, want, notWant []string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(html, w) {
+			t.Errorf("missing %q in\n%s", w, html)
+		}
+	}
+	for _, w := range notWant {
+		if strings.Contains(html, w) {
+			t.Errorf("unexpected %q in\n%s", w, html)
+		}
+	}
+}
+
+func TestFindTitle(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{"atx", "# Title\n\ntext\n", "Title"},
+		{"trailing whitespace", "# Title  \t\n", "Title"},
+		{"closing hashes", "# Title ##\n", "Title"},
+		{"crlf", "# Title\r\n\r\ntext\r\n", "Title"},
+		{"indented", "   # Title\n", "Title"},
+		{"setext", "Title\n=====\n\ntext\n", "Title"},
+		{"setext trailing whitespace", "Title   \n===\n", "Title"},
+		{"frontmatter title", "---\ntitle: From FM\n---\n# Heading\n", "From FM"},
+		{"frontmatter without title", "---\nauthor: x\n---\n\nAfter\n=====\n", "After"},
+		{"code block comment", "```sh\n# not a title\n```\n\n# Title\n", "Title"},
+		{"inline markup", "# The *best* `title`\n", "The best title"},
+		{"only h2", "## Section\n", ""},
+		{"setext h2", "Section\n-------\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findTitle([]byte(tt.md)); got != tt.want {
+				t.Errorf("findTitle(%q) = %q, want %q", tt.md, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	md := "Claim[^1].\n\n[^1]: Source.\n"
+	assertHTML(t, renderHTML([]byte(md), testOptions()), []string{
+		`<sup id="fnref:1"><a href="#fn:1"`,
+		`<li id="fn:1">`,
+		`<a href="#fnref:1" class="footnote-backref"`,
+	}, []string{"[^1]"})
+}
+
+func TestEmoji(t *testing.T) {
+	opts := testOptions()
+	assertHTML(t, renderHTML([]byte(":rocket:"), opts), []string{"🚀"}, []string{":rocket:", "<img"})
+
+	opts.twemoji = true
+	assertHTML(t, renderHTML([]byte(":rocket:"), opts), []string{
+		`<img class="emoji"`,
+		`alt="rocket"`,
+		`/1f680.png"`,
+	}, []string{":rocket:"})
+
+	opts.noEmoji = true
+	assertHTML(t, renderHTML([]byte(":rocket:"), opts), []string{":rocket:"}, []string{"🚀", "<img"})
+}
+
+func TestIsHiddenPath(t *testing.T) {
+	for name, want := range map[string]bool{
+		".env":           true,
+		".git/config":    true,
+		"docs/.secret":   true,
+		"a/.hidden/b.md": true,
+		"img/a.png":      false,
+		"notes.md":       false,
+		"":               false,
+	} {
+		if got := isHiddenPath(name); got != want {
+			t.Errorf("isHiddenPath(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseFlagsInlineValues(t *testing.T) {
+	opts, args, err := parseFlags([]string{"--width=30", "--title=A=B", "a.md"}, testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.width != 30 || opts.title != "A=B" || len(args) != 1 || args[0] != "a.md" {
+		t.Errorf("got width %d, title %q, args %q", opts.width, opts.title, args)
+	}
+
+	if _, _, err := parseFlags([]string{"--toc=true", "a.md"}, testOptions()); err == nil || err.Error() != "flag --toc does not take a value" {
+		t.Errorf("--toc=true: got error %v", err)
+	}
+
+	_, args, err = parseFlags([]string{"--unknown=x"}, testOptions())
+	if err != nil || len(args) != 1 || args[0] != "--unknown=x" {
+		t.Errorf("--unknown=x: got %q, %v", args, err)
+	}
+}
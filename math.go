@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Math ---
+
+// mathExtension parses $inline$ and $$display$$ TeX. Nodes are rendered as
+// escaped TeX inside .math elements that KaTeX typesets in the browser.
+// Escaped dollars (\$) never reach the parser, so they stay literal.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 150)),
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 150)))
+}
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+type mathInline struct {
+	ast.BaseInline
+	display bool
+	value   []byte
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMath }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": string(n.value)}, nil)
+}
+
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) IsRaw() bool { return true }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	open := 1
+	if len(line) > 1 && line[1] == '$' {
+		open = 2
+	}
+	display := open == 2
+
+	// Like pandoc, inline math must hug its delimiters so prices such as
+	// "$5 and $10" stay plain text.
+	if len(line) <= open || (!display && util.IsSpace(line[open])) {
+		return nil
+	}
+
+	for i := open; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] != '$':
+		case display:
+			if i+1 < len(line) && line[i+1] == '$' && i > open {
+				block.Advance(i + 2)
+				return &mathInline{display: true, value: line[open:i]}
+			}
+			return nil
+		case util.IsSpace(line[i-1]) || (i+1 < len(line) && util.IsNumeric(line[i+1])):
+		default:
+			block.Advance(i + 1)
+			return &mathInline{value: line[open:i]}
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+var mathBlockClosedKey = parser.NewContextKey()
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &mathBlock{}
+	rest := util.TrimRightSpace(line[pos+2:])
+	if len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+		// $$ ... $$ on a single line.
+		start := segment.Start + pos + 2
+		node.Lines().Append(text.NewSegment(start, start+len(rest)-2))
+		pc.Set(mathBlockClosedKey, true)
+	} else if !util.IsBlank(rest) {
+		return nil, parser.NoChildren
+	}
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	if pc.Get(mathBlockClosedKey) != nil {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		reader.Advance(segment.Len())
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	pc.Set(mathBlockClosedKey, nil)
+}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.renderInline)
+	reg.Register(kindMathBlock, r.renderBlock)
+}
+
+func (mathRenderer) renderInline(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	m := n.(*mathInline)
+	class := "math inline"
+	if m.display {
+		class = "math display"
+	}
+	w.WriteString(`<span class="` + class + `">`)
+	w.Write(util.EscapeHTML(m.value))
+	w.WriteString("</span>")
+	return ast.WalkSkipChildren, nil
+}
+
+func (mathRenderer) renderBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<div class="math display">`)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	w.WriteString("</div>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Mermaid ---
+
+// mermaidExtension swaps ```mermaid fences for a dedicated node before
+// rendering, so they bypass syntax highlighting and come out as
+// <div class="mermaid"> for Mermaid JS to draw. Other fences are untouched.
+type mermaidExtension struct{}
+
+func (mermaidExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(mermaidTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mermaidRenderer{}, 100)))
+}
+
+var kindMermaid = ast.NewNodeKind("Mermaid")
+
+type mermaidBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mermaidBlock) Kind() ast.NodeKind { return kindMermaid }
+
+func (n *mermaidBlock) IsRaw() bool { return true }
+
+func (n *mermaidBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mermaidTransformer struct{}
+
+func (mermaidTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var fences []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fence, ok := n.(*ast.FencedCodeBlock); ok && entering && string(fence.Language(source)) == "mermaid" {
+			fences = append(fences, fence)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, fence := range fences {
+		block := &mermaidBlock{}
+		block.SetLines(fence.Lines())
+		fence.Parent().ReplaceChild(fence.Parent(), fence, block)
+	}
+}
+
+type mermaidRenderer struct{}
+
+func (r mermaidRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMermaid, r.render)
+}
+
+func (mermaidRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<div class="mermaid">`)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	w.WriteString("</div>\n")
+	return ast.WalkSkipChildren, nil
+}
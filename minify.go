@@ -0,0 +1,93 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// --- Minify ---
+
+// rawElements are not minified as markup: whitespace is significant in
+// <pre> and <textarea>, scripts may rely on line breaks, and styles are
+// minified separately.
+var rawElements = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<textarea\b.*?</textarea>|(<style\b[^>]*>)(.*?)(</style>)|<script\b.*?</script>`)
+
+const blockTags = `(?:html|head|body|meta|title|link|style|script|div|p|h[1-6]|ul|ol|li|dl|dt|dd|table|thead|tbody|tr|th|td|blockquote|pre|hr|nav|article|section|header|footer|details|summary)`
+
+var (
+	lineBreaks  = regexp.MustCompile(`[ \t\r]*\n\s*`)
+	indentation = regexp.MustCompile(`(?m)^[ \t]+`)
+	beforeBlock = regexp.MustCompile(`(?i)\s+(</?` + blockTags + `\b)`)
+	afterBlock  = regexp.MustCompile(`(?i)(</?` + blockTags + `\b[^>]*>)\s+`)
+)
+
+// minifyHTML drops the whitespace of a reader page that does not affect
+// rendering: indentation, and line breaks next to block elements.
+func minifyHTML(page string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range rawElements.FindAllStringSubmatchIndex(page, -1) {
+		b.WriteString(strings.TrimSpace(minifyMarkup(page[last:m[0]])))
+		raw := page[m[0]:m[1]]
+		switch {
+		case m[4] >= 0:
+			raw = page[m[2]:m[3]] + minifyCSS(page[m[4]:m[5]]) + page[m[6]:m[7]]
+		case strings.HasPrefix(raw, "<script") && !strings.Contains(raw, "`"):
+			// Without template literals, indentation never matters.
+			raw = indentation.ReplaceAllString(raw, "")
+		}
+		b.WriteString(raw)
+		last = m[1]
+	}
+	b.WriteString(strings.TrimSpace(minifyMarkup(page[last:])))
+	return b.String()
+}
+
+func minifyMarkup(s string) string {
+	s = lineBreaks.ReplaceAllString(s, "\n")
+	s = beforeBlock.ReplaceAllString(s, "$1")
+	return afterBlock.ReplaceAllString(s, "$1")
+}
+
+// minifyCSS removes comments and the whitespace around punctuation,
+// leaving quoted strings alone.
+func minifyCSS(css string) string {
+	var out []byte
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(css) && css[j] != c {
+				if css[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j, len(css)-1)
+			out = append(out, css[i:j+1]...)
+			i = j
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return string(out)
+			}
+			i += end + 3
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			j := i
+			for j < len(css) && strings.IndexByte(" \t\n\r", css[j]) >= 0 {
+				j++
+			}
+			if len(out) > 0 && j < len(css) &&
+				strings.IndexByte("{};:,>", out[len(out)-1]) < 0 && strings.IndexByte("{};:,>)", css[j]) < 0 {
+				out = append(out, ' ')
+			}
+			i = j - 1
+		case c == '}' && len(out) > 0 && out[len(out)-1] == ';':
+			out[len(out)-1] = '}'
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// --- Terminal hyperlinks ---
+
+// osc8Supported reports whether the terminal is likely to understand OSC 8
+// hyperlinks. Terminals that do not usually ignore them, but the Linux
+// console and dumb terminals print them as garbage.
+func osc8Supported() bool {
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb" && !strings.HasPrefix(term, "linux")
+}
+
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// addHyperlinks makes the absolute URLs that glamour prints after each
+// link, and the link text just before them, clickable. Links are matched in
+// document order; any that cannot be found, for example because they were
+// wrapped, are left alone.
+func addHyperlinks(rendered string, links []outlineLink) string {
+	var b strings.Builder
+	rest := rendered
+	for _, l := range links {
+		if !strings.Contains(l.URL, "://") && !strings.HasPrefix(l.URL, "mailto:") {
+			continue
+		}
+		i := strings.Index(rest, l.URL)
+		if i < 0 {
+			continue
+		}
+		before := rest[:i]
+		if l.Text != "" && l.Text != l.URL {
+			if j := strings.LastIndex(before, l.Text); j >= 0 {
+				before = before[:j] + hyperlink(l.URL, l.Text) + before[j+len(l.Text):]
+			}
+		}
+		b.WriteString(before)
+		b.WriteString(hyperlink(l.URL, l.URL))
+		rest = rest[i+len(l.URL):]
+	}
+	b.WriteString(rest)
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// --- JSON outline ---
+
+// outline is the document structure printed by --json.
+type outline struct {
+	Title      string             `json:"title"`
+	Headings   []outlineHeading   `json:"headings"`
+	Links      []outlineLink      `json:"links"`
+	Images     []outlineImage     `json:"images"`
+	CodeBlocks []outlineCodeBlock `json:"code_blocks"`
+}
+
+type outlineHeading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+type outlineLink struct {
+	URL  string `json:"url"`
+	Text string `json:"text"`
+}
+
+type outlineImage struct {
+	URL string `json:"url"`
+	Alt string `json:"alt"`
+}
+
+type outlineCodeBlock struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+func collectOutline(md []byte, opts options) outline {
+	o := outline{
+		Title:      findTitle(md),
+		Headings:   []outlineHeading{},
+		Links:      []outlineLink{},
+		Images:     []outlineImage{},
+		CodeBlocks: []outlineCodeBlock{},
+	}
+	_, md = stripFrontmatter(md)
+
+	ast.Walk(parseMarkdown(md, opts), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			o.Headings = append(o.Headings, outlineHeading{Level: n.Level, ID: headingID(n), Text: plainText(n, md)})
+		case *ast.Link:
+			o.Links = append(o.Links, outlineLink{URL: string(n.Destination), Text: plainText(n, md)})
+		case *ast.AutoLink:
+			o.Links = append(o.Links, outlineLink{URL: string(n.URL(md)), Text: string(n.Label(md))})
+		case *ast.Image:
+			o.Images = append(o.Images, outlineImage{URL: string(n.Destination), Alt: plainText(n, md)})
+		case *ast.FencedCodeBlock:
+			o.CodeBlocks = append(o.CodeBlocks, outlineCodeBlock{Language: string(n.Language(md)), Code: blockText(n, md)})
+		case *ast.CodeBlock:
+			o.CodeBlocks = append(o.CodeBlocks, outlineCodeBlock{Code: blockText(n, md)})
+		}
+		return ast.WalkContinue, nil
+	})
+	return o
+}
+
+// blockText returns the raw lines of a block node.
+func blockText(n ast.Node, source []byte) string {
+	var b bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		b.Write(line.Value(source))
+	}
+	return b.String()
+}
+
+func printOutline(md []byte, opts options) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(collectOutline(md, opts))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yuin/goldmark/ast"
+	"golang.org/x/term"
+)
+
+// --- Paging by heading ---
+
+// splitSections cuts md before every heading of depth or a higher level.
+// A depth of 0 splits at the highest level the document uses. Text before
+// the first such heading becomes a section of its own.
+func splitSections(md []byte, depth int, opts options) [][]byte {
+	var headings []*ast.Heading
+	root := parseMarkdown(md, opts)
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok {
+			headings = append(headings, h)
+		}
+	}
+	if depth == 0 {
+		depth = 6
+		for _, h := range headings {
+			depth = min(depth, h.Level)
+		}
+	}
+
+	var sections [][]byte
+	prev := 0
+	for _, h := range headings {
+		start := lineStart(h, md)
+		if h.Level > depth || start <= prev {
+			continue
+		}
+		if len(bytes.TrimSpace(md[prev:start])) > 0 {
+			sections = append(sections, md[prev:start])
+		}
+		prev = start
+	}
+	return append(sections, md[prev:])
+}
+
+// paginateSections renders one section at a time and waits for Enter
+// before the next. Ctrl+C stops. When there is no terminal to read from,
+// the sections are printed one after another.
+func paginateSections(md []byte, width int, opts options) error {
+	var keys *bufio.Reader
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		keys = bufio.NewReader(os.Stdin)
+	} else if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		keys = bufio.NewReader(tty)
+	}
+
+	sections := splitSections(md, opts.paginateDepth, opts)
+	for i, section := range sections {
+		rendered, err := render(section, width, opts)
+		if err != nil {
+			return fmt.Errorf("render failed: %w", err)
+		}
+		output(rendered, opts)
+
+		if i == len(sections)-1 || keys == nil || !stdoutIsTTY() {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "-- section %d of %d: Enter for the next, Ctrl+C to quit --", i+1, len(sections))
+		if _, err := keys.ReadString('\n'); err == io.EOF {
+			fmt.Fprintln(os.Stderr)
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// --- Sections ---
+
+// extractSection cuts md down to the section under the heading named
+// name, up to the next heading of the same or a higher level. Names match
+// case-insensitively. The frontmatter is kept so the title still applies.
+func extractSection(doc document, name string, opts options) ([]byte, error) {
+	_, body := stripFrontmatter(doc.md)
+	front := doc.md[:len(doc.md)-len(body)]
+	name = strings.TrimSpace(name)
+
+	var headings []*ast.Heading
+	root := parseMarkdown(body, opts)
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok {
+			headings = append(headings, h)
+		}
+	}
+
+	for i, h := range headings {
+		if !strings.EqualFold(plainText(h, body), name) {
+			continue
+		}
+		end := len(body)
+		for _, next := range headings[i+1:] {
+			if start := lineStart(next, body); next.Level <= h.Level && start >= 0 {
+				end = start
+				break
+			}
+		}
+		if start := lineStart(h, body); start >= 0 {
+			return append(bytes.Clone(front), body[start:end]...), nil
+		}
+	}
+
+	source := doc.path
+	if source == "" {
+		source = "input"
+	}
+	if len(headings) == 0 {
+		return nil, fmt.Errorf("no section %q in %s, which has no headings", name, source)
+	}
+	top := headings[0].Level
+	for _, h := range headings {
+		top = min(top, h.Level)
+	}
+	var names []string
+	for _, h := range headings {
+		if h.Level == top {
+			names = append(names, fmt.Sprintf("%q", plainText(h, body)))
+		}
+	}
+	return nil, fmt.Errorf("no section %q in %s (top-level headings: %s)", name, source, strings.Join(names, ", "))
+}
+
+// lineStart returns the offset of the line a heading starts on, including
+// its # markers, or -1 for an empty heading, which has no position.
+func lineStart(h *ast.Heading, source []byte) int {
+	if h.Lines().Len() == 0 {
+		return -1
+	}
+	return bytes.LastIndexByte(source[:h.Lines().At(0).Start], '\n') + 1
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// --- Directory server ---
+
+// serveDir serves an index of the markdown files under dir and renders each
+// one when it is requested, so edits show up on reload.
+func serveDir(dir string, opts options) error {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	ln, readerURL, err := listen(opts)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	srv := &http.Server{Handler: mux}
+
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		p := dirIndex(dir)
+		p.heartbeat = opts.closeOnExit
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, readerPage(p, opts))
+	})
+
+	assets, err := serveAssets(mux, "/", dir, func(w http.ResponseWriter, doc document) {
+		writeLinkedPage(w, doc, "/", "", opts)
+	})
+	if err != nil {
+		return err
+	}
+	defer assets.Close()
+
+	return serveReader(srv, mux, ln, readerURL, opts)
+}
+
+// dirIndex lists the markdown files under dir by title, grouped by folder.
+// Hidden folders such as .git are skipped.
+func dirIndex(dir string) page {
+	var files []string
+	filepath.WalkDir(dir, func(name string, e fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case e.IsDir() && name != dir && strings.HasPrefix(e.Name(), "."):
+			return filepath.SkipDir
+		case !e.IsDir() && slices.Contains(markdownExts, strings.ToLower(filepath.Ext(name))):
+			rel, _ := filepath.Rel(dir, name)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	// Files in a folder come before its subfolders.
+	slices.SortStableFunc(files, func(a, b string) int {
+		return strings.Compare(path.Dir(a), path.Dir(b))
+	})
+
+	abs, _ := filepath.Abs(dir)
+	title := filepath.Base(abs)
+
+	var b strings.Builder
+	b.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	if len(files) == 0 {
+		b.WriteString("<p>No markdown files found.</p>\n")
+	}
+	folder := ""
+	for i, name := range files {
+		if d := path.Dir(name); i == 0 || d != folder {
+			if i > 0 {
+				b.WriteString("</ul>\n")
+			}
+			folder = d
+			if folder != "." {
+				b.WriteString("<h2>" + html.EscapeString(folder+"/") + "</h2>\n")
+			}
+			b.WriteString("<ul>\n")
+		}
+
+		href := html.EscapeString((&url.URL{Path: name}).EscapedPath())
+		label := html.EscapeString(path.Base(name))
+		if md, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			if t := findTitle(md); t != "" {
+				label = html.EscapeString(t) + `<span class="index-path">` + label + `</span>`
+			}
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", href, label)
+	}
+	if len(files) > 0 {
+		b.WriteString("</ul>\n")
+	}
+	return page{title: title, body: b.String()}
+}
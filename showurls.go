@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// --- Inline link targets ---
+
+// showURLs rewrites every link as its text followed by the target in
+// parentheses, for terminals where links cannot be clicked. Autolinks and
+// images are left alone, as are links with no text of their own, such as
+// badges.
+func showURLs(md []byte) []byte {
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(md))
+
+	var out bytes.Buffer
+	last := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		start, end, label, ok := linkSpan(link, md)
+		if !ok || start < last {
+			return ast.WalkSkipChildren, nil
+		}
+		out.Write(md[last:start])
+		out.Write(label)
+		out.WriteString(" (")
+		out.Write(link.Destination)
+		out.WriteString(")")
+		last = end
+		return ast.WalkSkipChildren, nil
+	})
+	out.Write(md[last:])
+	return out.Bytes()
+}
+
+// linkSpan finds the source range of a link, from its "[" to the end of
+// its (destination) or [reference], and the label between the brackets.
+// goldmark only records the positions of the link text, so the brackets are
+// located around it.
+func linkSpan(link *ast.Link, md []byte) (start, end int, label []byte, ok bool) {
+	from, to := -1, -1
+	ast.Walk(link, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.Image:
+			// The brackets of an image alt text would be mistaken for
+			// the link's own.
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				if from < 0 {
+					from = n.Segment.Start
+				}
+				to = n.Segment.Stop
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if from < 0 {
+		return 0, 0, nil, false
+	}
+	start = bytes.LastIndexByte(md[:from], '[')
+	closing := bytes.IndexByte(md[to:], ']')
+	if start < 0 || closing < 0 {
+		return 0, 0, nil, false
+	}
+	label = md[start+1 : to+closing]
+	end = to + closing + 1
+
+	switch {
+	case end < len(md) && md[end] == '(':
+		depth := 0
+		for i := end; i < len(md); i++ {
+			switch md[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					return start, i + 1, label, true
+				}
+			}
+		}
+		return 0, 0, nil, false
+	case end < len(md) && md[end] == '[':
+		if i := bytes.IndexByte(md[end:], ']'); i >= 0 {
+			end += i + 1
+		}
+	}
+	return start, end, label, true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- Changes since a git ref ---
+
+// changedSince cuts doc down to the lines added since ref, comparing with
+// the version git has at that ref. Separate runs of added lines become
+// separate paragraphs. The frontmatter is kept so the title still applies.
+func changedSince(doc document, ref string) ([]byte, error) {
+	if doc.path == "" {
+		return nil, errors.New("--since needs a file, not stdin or a URL")
+	}
+	dir, name := filepath.Split(doc.path)
+	if dir == "" {
+		dir = "."
+	}
+	git := func(args ...string) ([]byte, error) {
+		return exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.New("--since needs git, which is not installed")
+	}
+	if _, err := git("rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository", doc.path)
+	}
+	if _, err := git("rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+	// A file that did not exist at ref is new from top to bottom.
+	old, err := git("show", ref+":./"+name)
+	if err != nil {
+		old = nil
+	}
+
+	_, body := stripFrontmatter(doc.md)
+	front := doc.md[:len(doc.md)-len(body)]
+	_, old = stripFrontmatter(old)
+
+	var added []string
+	prev := byte(' ')
+	for _, l := range diffLines(splitLines(old), splitLines(body)) {
+		if l.op == '+' {
+			if prev != '+' && len(added) > 0 {
+				added = append(added, "")
+			}
+			added = append(added, l.text)
+		}
+		prev = l.op
+	}
+	if len(added) == 0 {
+		return nil, fmt.Errorf("%s has no additions since %s", doc.path, ref)
+	}
+	return append(bytes.Clone(front), strings.Join(added, "\n")+"\n"...), nil
+}
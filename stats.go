@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// --- Statistics ---
+
+// wordsPerMinute is the reading speed used for the time estimate.
+const wordsPerMinute = 200
+
+type docStats struct {
+	words, chars, headings, codeBlocks, links int
+}
+
+// collectStats counts the prose of a document. Code blocks and raw HTML are
+// counted as blocks but their contents are not counted as words.
+func collectStats(md []byte, opts options) docStats {
+	var s docStats
+	ast.Walk(parseMarkdown(md, opts), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			s.headings++
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			s.codeBlocks++
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Link, *ast.AutoLink:
+			s.links++
+		case *ast.Text:
+			s.count(n.Segment.Value(md))
+		case *ast.String:
+			s.count(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return s
+}
+
+func (s *docStats) count(text []byte) {
+	s.words += len(bytes.Fields(text))
+	s.chars += utf8.RuneCount(text)
+}
+
+func printStats(md []byte, opts options) {
+	_, md = stripFrontmatter(md)
+	s := collectStats(md, opts)
+	minutes := (s.words + wordsPerMinute - 1) / wordsPerMinute
+
+	fmt.Printf("Words:         %d\n", s.words)
+	fmt.Printf("Characters:    %d\n", s.chars)
+	fmt.Printf("Headings:      %d\n", s.headings)
+	fmt.Printf("Code blocks:   %d\n", s.codeBlocks)
+	fmt.Printf("Links:         %d\n", s.links)
+	fmt.Printf("Reading time:  %d min\n", minutes)
+}
+
+// summary returns the plain text of the first paragraph, skipping headings.
+// Documents without prose fall back to their first code block.
+func summary(md []byte, opts options) string {
+	var text, code string
+	ast.Walk(parseMarkdown(md, opts), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *ast.Heading:
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph, *ast.TextBlock:
+			if text = plainText(n, md); text != "" {
+				return ast.WalkStop, nil
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if code == "" {
+				code = strings.TrimSpace(blockText(n, md))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if text == "" {
+		return code
+	}
+	return text
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Subscript and superscript ---
+
+// subSupExtension renders H~2~O and x^2^ with <sub> and <sup>, as pandoc
+// does. The text in between may not contain spaces, so a stray ~ or ^ is
+// left alone, and ~~ is still strikethrough.
+type subSupExtension struct{}
+
+func (subSupExtension) Extend(m goldmark.Markdown) {
+	// Run before the strikethrough parser, which also takes single tildes.
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(subSupParser{}, 150)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(subSupRenderer{}, 150)))
+}
+
+var kindSubSup = ast.NewNodeKind("SubSup")
+
+// subSupInline holds its text as an ast.String child, so headings with
+// H~2~O still read H2O in titles and the table of contents.
+type subSupInline struct {
+	ast.BaseInline
+	tag string // "sub" or "sup"
+}
+
+func (n *subSupInline) Kind() ast.NodeKind { return kindSubSup }
+
+func (n *subSupInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Tag": n.tag}, nil)
+}
+
+type subSupParser struct{}
+
+func (subSupParser) Trigger() []byte {
+	return []byte{'~', '^'}
+}
+
+func (subSupParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	mark := line[0]
+	if len(line) < 3 || line[1] == mark || block.PrecendingCharacter() == rune(mark) {
+		return nil
+	}
+
+	for i := 1; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case util.IsSpace(line[i]):
+			return nil
+		case line[i] == mark && i > 1:
+			tag := "sup"
+			if mark == '~' {
+				tag = "sub"
+			}
+			node := &subSupInline{tag: tag}
+			node.AppendChild(node, ast.NewString(util.UnescapePunctuations(line[1:i])))
+			block.Advance(i + 1)
+			return node
+		}
+	}
+	return nil
+}
+
+type subSupRenderer struct{}
+
+func (r subSupRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindSubSup, r.render)
+}
+
+func (subSupRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	tag := n.(*subSupInline).tag
+	if entering {
+		w.WriteString("<" + tag + ">")
+	} else {
+		w.WriteString("</" + tag + ">")
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSubSup(t *testing.T) {
+	tests := []struct {
+		name, md      string
+		want, notWant []string
+	}{
+		{"subscript", "H~2~O", []string{"H<sub>2</sub>O"}, []string{"<del>"}},
+		{"superscript", "x^2^", []string{"x<sup>2</sup>"}, nil},
+		{"strikethrough", "~~strike~~", []string{"<del>strike</del>"}, []string{"<sub>"}},
+		{"strike and sub", "~~gone~~ and ~sub~", []string{"<del>gone</del>", "<sub>sub</sub>"}, nil},
+		{
+			"footnote next to superscript",
+			"Note[^1] and ^sup^\n\n[^1]: foot\n",
+			[]string{`<a href="#fn:1" class="footnote-ref"`, "<sup>sup</sup>"},
+			[]string{"<sup>1]"},
+		},
+		{"paths", "see ~/path and ~/bin", []string{"~/path and ~/bin"}, []string{"<sub>"}},
+		{"spaces", "2^10 and 3^4", []string{"2^10 and 3^4"}, []string{"<sup>"}},
+		{"escaped", `H~<i>~ a\^b^c`, []string{"H<sub>&lt;i&gt;</sub>", "a^b^c"}, []string{"<i>"}},
+		{"code span", "`H~2~O`", []string{"<code>H~2~O</code>"}, []string{"<sub>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertHTML(t, renderHTML([]byte(tt.md), testOptions()), tt.want, tt.notWant)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Task lists ---
+
+// taskExtension renders GFM task list checkboxes as enabled inputs so they
+// can be ticked in the reader. The state only lives in the browser.
+type taskExtension struct{}
+
+func (taskExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(taskRenderer{}, 100)))
+}
+
+type taskRenderer struct{}
+
+func (r taskRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(east.KindTaskCheckBox, r.render)
+}
+
+func (taskRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<input type="checkbox" class="task"`)
+	if n.(*east.TaskCheckBox).IsChecked {
+		w.WriteString(` checked=""`)
+	}
+	w.WriteString("> ")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// --- Page templates ---
+
+// templateData is what a --template file can use. Title is HTML-escaped;
+// Body and TOC are HTML, Style is the reader CSS without <style> tags.
+type templateData struct {
+	Title string
+	Body  string
+	TOC   string
+	Style string
+}
+
+// loadTemplate parses a page template and tries it on an empty page, so
+// mistakes show up before anything is served or written.
+func loadTemplate(path string) (*template.Template, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	t, err := template.New(path).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, templateData{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func templatePage(p page, opts options) string {
+	var b strings.Builder
+	err := opts.pageTemplate.Execute(&b, templateData{
+		Title: html.EscapeString(p.title),
+		Body:  p.body,
+		TOC:   p.toc,
+		Style: readerStyle(p, opts),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marko: %s\n", err)
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// --- Themes ---
+
+type palette struct {
+	dark bool
+	vars string
+}
+
+// palettes are the reader color themes selectable with --theme.
+var palettes = map[string]palette{
+	"github-light": {vars: `
+  --bg: #ffffff;
+  --fg: #24292e;
+  --secondary: #586069;
+  --border: #e1e4e8;
+  --code-bg: #f6f8fa;
+  --link: #0366d6;
+  --quote-border: #dfe2e5;
+  --table-border: #dfe2e5;
+  --note: #0969da;
+  --tip: #1a7f37;
+  --important: #8250df;
+  --warning: #9a6700;
+  --caution: #cf222e;`},
+	"github-dark": {dark: true, vars: `
+  --bg: #0d1117;
+  --fg: #c9d1d9;
+  --secondary: #8b949e;
+  --border: #30363d;
+  --code-bg: #161b22;
+  --link: #58a6ff;
+  --quote-border: #3b434b;
+  --table-border: #30363d;
+  --note: #4493f8;
+  --tip: #3fb950;
+  --important: #ab7df8;
+  --warning: #d29922;
+  --caution: #f85149;`},
+	"dracula": {dark: true, vars: `
+  --bg: #282a36;
+  --fg: #f8f8f2;
+  --secondary: #6272a4;
+  --border: #44475a;
+  --code-bg: #21222c;
+  --link: #8be9fd;
+  --quote-border: #6272a4;
+  --table-border: #44475a;
+  --note: #8be9fd;
+  --tip: #50fa7b;
+  --important: #bd93f9;
+  --warning: #f1fa8c;
+  --caution: #ff5555;`},
+	"solarized": {vars: `
+  --bg: #fdf6e3;
+  --fg: #586e75;
+  --secondary: #93a1a1;
+  --border: #eee8d5;
+  --code-bg: #eee8d5;
+  --link: #268bd2;
+  --quote-border: #93a1a1;
+  --table-border: #eee8d5;
+  --note: #268bd2;
+  --tip: #859900;
+  --important: #6c71c4;
+  --warning: #b58900;
+  --caution: #dc322f;`},
+}
+
+// readerFonts are the font stacks selectable with --reader-font.
+var readerFonts = map[string]string{
+	"sans":  `-apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif`,
+	"serif": `Charter, "Bitstream Charter", "Sitka Text", Cambria, Georgia, serif`,
+	"mono":  `"SFMono-Regular", Consolas, "Liberation Mono", Menlo, monospace`,
+}
+
+func validateTheme(opts options) error {
+	switch {
+	case opts.theme == "custom":
+		if opts.cssFile == "" {
+			return fmt.Errorf("--theme custom requires --css or --css-replace")
+		}
+		return nil
+	case opts.theme == "":
+		return nil
+	case palettes[opts.theme].vars != "":
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(palettes))
+	return fmt.Errorf("unknown theme %q (valid: %s, custom)", opts.theme, strings.Join(names, ", "))
+}
+
+// fixedTheme returns the theme the page is locked to, or "" when it follows
+// the OS preference and offers a toggle.
+func fixedTheme(p page, opts options) string {
+	if p.light {
+		return "github-light"
+	}
+	return opts.theme
+}
+
+// colorScheme sets the palette variables. Unless a theme is fixed, the dark
+// palette follows the OS preference, and the toggle can override it by
+// setting data-theme on the root element. Printing always uses the light
+// palette, see printStyle.
+func colorScheme(p page, opts options) string {
+	switch theme := fixedTheme(p, opts); theme {
+	case "custom":
+		return ""
+	case "":
+	default:
+		return ":root {" + palettes[theme].vars + "\n}"
+	}
+
+	light, dark := palettes["github-light"].vars, palettes["github-dark"].vars
+	return `:root {` + light + `
+}
+@media screen and (prefers-color-scheme: dark) {
+  :root:not([data-theme="light"]) {` + dark + `
+  }
+}
+@media screen {
+  :root[data-theme="dark"] {` + dark + `
+  }
+}`
+}
+
+func themeToggle(p page, opts options) string {
+	if fixedTheme(p, opts) != "" {
+		return ""
+	}
+	return `<button class="theme-toggle" id="theme-toggle" type="button" aria-label="Toggle dark mode" title="Toggle dark mode">◐</button>
+`
+}
+
+// themeInit applies a saved theme before first paint to avoid a flash.
+func themeInit(p page, opts options) string {
+	if fixedTheme(p, opts) != "" {
+		return ""
+	}
+	return `
+<script>
+var savedTheme = localStorage.getItem("marko-theme");
+if (savedTheme) document.documentElement.setAttribute("data-theme", savedTheme);
+</script>`
+}
+
+// printStyle makes browser printing ink friendly: black on white, full
+// width, and without the reader controls.
+func printStyle() string {
+	return `
+@media print {
+  :root {` + strings.ReplaceAll(palettes["github-light"].vars, "\n", "\n  ") + `
+  }
+  body, body.has-sidebar, body:has(.toc:not(:empty)) { padding: 0; color: #000; background: #fff; }
+  article { max-width: none; }
+  .sidebar, .toc, .theme-toggle, .progress, .back-button, .copy-button, .source-link, .shortcuts, .search { display: none; }
+  h1, h2, h3, h4, h5, h6 { break-after: avoid; }
+  pre, blockquote, table, img, .admonition { break-inside: avoid; }
+  pre { white-space: pre-wrap; }
+}`
+}
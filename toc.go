@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// --- Table of contents ---
+
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+func collectHeadings(md []byte, opts options) []heading {
+	var headings []heading
+	ast.Walk(parseMarkdown(md, opts), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if h.Level > opts.tocDepth {
+			return ast.WalkSkipChildren, nil
+		}
+		headings = append(headings, heading{level: h.Level, id: headingID(h), text: plainText(h, md)})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// headingID returns the id assigned by parser.WithAutoHeadingID, or "".
+func headingID(h *ast.Heading) string {
+	if v, ok := h.AttributeString("id"); ok {
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// renderTOC builds a nested list of links to the headings. Documents with
+// fewer than two headings get no table of contents.
+func renderTOC(headings []heading) string {
+	if len(headings) < 2 {
+		return ""
+	}
+
+	top := headings[0].level
+	for _, h := range headings {
+		top = min(top, h.level)
+	}
+
+	var b strings.Builder
+	depth := 0
+	for _, h := range headings {
+		level := h.level - top + 1
+		if level > depth {
+			for ; depth < level; depth++ {
+				b.WriteString("<ul><li>")
+			}
+		} else {
+			for ; depth > level; depth-- {
+				b.WriteString("</li></ul>")
+			}
+			b.WriteString("</li><li>")
+		}
+		fmt.Fprintf(&b, `<a href="#%s" class="toc-h%d">%s</a>`, h.id, h.level, html.EscapeString(h.text))
+	}
+	for ; depth > 0; depth-- {
+		b.WriteString("</li></ul>")
+	}
+	return b.String()
+}
+
+// plainText flattens the inline content of n, dropping any markup.
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// printTOC prints the headings as an indented outline, with their anchor
+// ids in a second column.
+func printTOC(md []byte, opts options) error {
+	headings := collectHeadings(md, opts)
+	if len(headings) == 0 {
+		return nil
+	}
+	top := headings[0].level
+	for _, h := range headings {
+		top = min(top, h.level)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, h := range headings {
+		fmt.Fprintf(tw, "%s%s\t#%s\n", strings.Repeat("  ", h.level-top), h.text, h.id)
+	}
+	return tw.Flush()
+}
+
+// headingLines lists each heading of doc as line:level:text, with line
+// numbers counted from the top of the file, frontmatter included. Includes
+// are not expanded, so their headings, which live in other files, are left
+// out.
+func headingLines(doc document, opts options) ([]string, error) {
+	md, err := gunzip(doc)
+	if err != nil {
+		return nil, err
+	}
+	if md, err = decodeInput(md, opts); err != nil {
+		return nil, err
+	}
+	_, body := stripFrontmatter(md)
+	offset := bytes.Count(md[:len(md)-len(body)], []byte("\n"))
+
+	var lines []string
+	ast.Walk(parseMarkdown(body, opts), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if start := lineStart(h, body); start >= 0 {
+			line := offset + bytes.Count(body[:start], []byte("\n")) + 1
+			lines = append(lines, fmt.Sprintf("%d:%d:%s", line, h.Level, plainText(h, body)))
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return lines, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestHeadingLines(t *testing.T) {
+	dir := t.TempDir()
+	doc := "---\ntitle: T\n---\n# Top\n\n{{include part.md}}\n\nSetext\n------\n\n```sh\n# not a heading\n```\n\n## After\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "part.md"), []byte("# Included\n\n## Also included\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := readFile(filepath.Join(dir, "doc.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := headingLines(d, testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"4:1:Top", "8:2:Setext", "15:2:After"}
+	if !slices.Equal(got, want) {
+		t.Errorf("headingLines = %q, want %q", got, want)
+	}
+}
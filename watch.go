@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/net/websocket"
+)
+
+// --- Live reload ---
+
+// hub fans out messages to every connected /events client.
+type hub struct {
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+	done    chan struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		clients: make(map[chan string]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (h *hub) subscribe() chan string {
+	ch := make(chan string, 1)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan string) {
+	h.mu.Lock()
+	delete(h.clients, ch)
+	h.mu.Unlock()
+}
+
+func (h *hub) broadcast(msg string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		// Drop a stale pending update so slow clients always get the latest one.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- msg
+	}
+}
+
+// close releases long-lived event streams so the server can shut down.
+func (h *hub) close() {
+	close(h.done)
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case msg := <-ch:
+			for _, line := range strings.Split(msg, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// serveWebSocket sends the same updates as ServeHTTP over a WebSocket, which
+// some proxies and port forwards pass through more reliably than SSE.
+func (h *hub) serveWebSocket() http.Handler {
+	return websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ch := h.subscribe()
+		defer h.unsubscribe(ch)
+
+		// The client never sends anything, so reading only returns once the
+		// connection is gone.
+		gone := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, ws)
+			close(gone)
+		}()
+
+		for {
+			select {
+			case <-gone:
+				return
+			case <-h.done:
+				return
+			case msg := <-ch:
+				if err := websocket.Message.Send(ws, msg); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// watchFile calls onChange with the new contents each time path is written.
+// The parent directory is watched so editors that save by renaming a
+// temporary file over the original are picked up too. Events are collected
+// until none has arrived for debounce, so a burst of writes is read once.
+func watchFile(path string, debounce time.Duration, onChange func(md []byte)) (io.Closer, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(filepath.Dir(abs)); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go func() {
+		var settled <-chan time.Time // nil until a change is pending
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Name != abs || !ev.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				settled = time.After(debounce)
+			case <-settled:
+				settled = nil
+				data, err := os.ReadFile(abs)
+				if err != nil || len(data) == 0 {
+					continue
+				}
+				onChange(data)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "marko: watch: %s\n", err)
+			}
+		}
+	}()
+
+	return w, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// --- Wikilinks ---
+
+// wikilinkExtension turns [[Page]] and [[Page|Label]] into links to Page.md,
+// so they can be followed with --follow-links.
+type wikilinkExtension struct{}
+
+func (wikilinkExtension) Extend(m goldmark.Markdown) {
+	// Run before the link parser, which also triggers on '['.
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(wikilinkParser{}, 150)))
+}
+
+type wikilinkParser struct{}
+
+func (wikilinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (wikilinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line, []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := line[2:end]
+	if bytes.ContainsAny(inner, "[]") {
+		return nil
+	}
+
+	target, label, ok := bytes.Cut(inner, []byte("|"))
+	if !ok {
+		label = target
+	}
+	target, label = bytes.TrimSpace(target), bytes.TrimSpace(label)
+	if len(target) == 0 || len(label) == 0 {
+		return nil
+	}
+
+	link := ast.NewLink()
+	link.Destination = wikilinkURL(target)
+	link.AppendChild(link, ast.NewString(label))
+	block.Advance(end + 2)
+	return link
+}
+
+// wikilinkURL maps a page name, optionally with a #section, to a relative
+// markdown file URL.
+func wikilinkURL(target []byte) []byte {
+	page, section, ok := bytes.Cut(target, []byte("#"))
+	dest := url.PathEscape(string(page)) + ".md"
+	if ok {
+		dest += "#" + url.PathEscape(string(section))
+	}
+	return []byte(dest)
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestWikilinks(t *testing.T) {
+	tests := []struct {
+		name, md string
+		want     string
+	}{
+		{"spaces", "[[Note Name]]", `<a href="Note%20Name.md">Note Name</a>`},
+		{"alias", "[[Target|Label]]", `<a href="Target.md">Label</a>`},
+		{"alias with spaces", "[[ My Page | the label ]]", `<a href="My%20Page.md">the label</a>`},
+		{"section", "[[Page#Sec]]", `<a href="Page.md#Sec">Page#Sec</a>`},
+		{"markdown link", "[x](y)", `<a href="y">x</a>`},
+		{"empty", "[[]]", "[[]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertHTML(t, renderHTML([]byte(tt.md), testOptions()), []string{tt.want}, nil)
+		})
+	}
+}